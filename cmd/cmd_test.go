@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -9,3 +10,19 @@ import (
  */
 func TestMain(testing *testing.T) {
 }
+
+func TestInputURLs(test *testing.T) {
+	args := []string{
+		"--input-url", "kafka://one:9092/records?broker=two:9092&broker=three:9092",
+		"--input-url", "file:///a.jsonl,file:///b.jsonl",
+		"--input-url", "nats://one:4222/records?server=two:4222",
+	}
+	if err := RootCmd.ParseFlags(args); err != nil {
+		test.Fatal(err)
+	}
+	loadOptions(RootCmd)
+	expected := []string{"kafka://one:9092/records?broker=two:9092&broker=three:9092", "file:///a.jsonl", "file:///b.jsonl", "nats://one:4222/records?server=two:4222"}
+	if actual := inputURLs(); !reflect.DeepEqual(actual, expected) {
+		test.Errorf("inputURLs() = %v, expected %v", actual, expected)
+	}
+}
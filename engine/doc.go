@@ -0,0 +1,11 @@
+/*
+The engine package is the part of the Senzing G2 engine that load uses;
+records are added, deleted and reevaluated, redo records are processed and
+the engine's statistics read.  the loader owns the engine and passes it to
+the inputs, so tests and library users can give them a stand-in instead.
+
+The G2 implementation uses a Senzing G2engine, either initialized in this
+process or on a remote Senzing gRPC server, and writes with-info results to
+an output writer when one is given.
+*/
+package engine
@@ -0,0 +1,201 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/roncewind/load/loaderror"
+	"github.com/roncewind/load/logging"
+	"github.com/roncewind/load/output"
+	"github.com/roncewind/load/redact"
+	"github.com/senzing/g2-sdk-go/g2api"
+	"github.com/senzing/go-sdk-abstract-factory/factory"
+)
+
+var log = logging.New("engine", map[int]string{
+	2601: "The Senzing engine is ready",
+	2602: "Destroyed the Senzing engine",
+})
+
+// the load ID records are added and deleted with
+const loadID = "Load"
+
+// ----------------------------------------------------------------------------
+// Types
+// ----------------------------------------------------------------------------
+
+// Engine is the part of the Senzing G2 engine that load uses.  it is safe for
+// use by concurrent workers.
+type Engine interface {
+	AddRecord(ctx context.Context, dataSourceCode, recordID, jsonData string) error
+	DeleteRecord(ctx context.Context, dataSourceCode, recordID string) error
+	// ProcessRedoRecord processes the next redo record, returning it, or an
+	// empty string when there are none.
+	ProcessRedoRecord(ctx context.Context) (string, error)
+	ReevaluateRecord(ctx context.Context, dataSourceCode, recordID string) error
+	Stats(ctx context.Context) (string, error)
+}
+
+// G2 is an Engine that uses a Senzing G2engine.  when it has an info writer,
+// the with-info variant of each call is used and its result written.
+type G2 struct {
+	g2engine   g2api.G2engine
+	grpcTarget string
+	infoWriter output.Writer
+}
+
+// ----------------------------------------------------------------------------
+
+// Check at compile time that the implementation adheres to the interface.
+var _ Engine = (*G2)(nil)
+
+// ----------------------------------------------------------------------------
+
+// New creates a G2 engine.  with a gRPC URL, eg. grpc://localhost:8258, it is
+// a client of that Senzing gRPC server, which is already initialized; it is
+// checked that the server can be reached instead.  otherwise the engine is
+// initialized in this process with the given configuration, see ConfigJSON,
+// once it has been checked.  when an info writer is given, records are loaded
+// with info and the results written to it.  the engine must be destroyed once
+// it is no longer used.
+func New(ctx context.Context, grpcURL, engineConfigJson string, engineLogLevel int, infoWriter output.Writer) (*G2, error) {
+	grpcTarget, err := grpcTarget(grpcURL)
+	if err != nil {
+		return nil, loaderror.ConfigurationError{Err: err}
+	}
+	senzingFactory := &factory.SdkAbstractFactoryImpl{GrpcTarget: grpcTarget}
+	g2Config, err := senzingFactory.GetG2config(ctx)
+	if err != nil {
+		return nil, loaderror.EngineError{Err: fmt.Errorf("unable to retrieve the config: %w", err)}
+	}
+	g2engine, err := senzingFactory.GetG2engine(ctx)
+	if err != nil {
+		return nil, loaderror.EngineError{Err: fmt.Errorf("unable to reach G2: %w", err)}
+	}
+	if len(grpcTarget) > 0 {
+		_, err = g2engine.GetActiveConfigID(ctx)
+		if err != nil {
+			return nil, loaderror.ConnectionError{Err: fmt.Errorf("unable to reach the Senzing gRPC server at %s: %w", grpcTarget, err)}
+		}
+	} else if g2Config.GetSdkId(ctx) == "base" {
+		err = checkConfigJSON(engineConfigJson)
+		if err != nil {
+			return nil, err
+		}
+		err = g2engine.Init(ctx, "load", engineConfigJson, engineLogLevel)
+		if err != nil {
+			return nil, initError(err)
+		}
+	}
+	log.Log(2601, "sdk", g2engine.GetSdkId(ctx), "grpcTarget", grpcTarget, "withInfo", infoWriter != nil)
+	return &G2{
+		g2engine:   g2engine,
+		grpcTarget: grpcTarget,
+		infoWriter: infoWriter,
+	}, nil
+}
+
+// ----------------------------------------------------------------------------
+
+// Destroy releases the engine.  an engine on a Senzing gRPC server is shared
+// with its other clients, so it is left running.
+func (engine *G2) Destroy(ctx context.Context) error {
+	if len(engine.grpcTarget) > 0 {
+		return nil
+	}
+	err := engine.g2engine.Destroy(ctx)
+	if err != nil {
+		return loaderror.EngineError{Err: fmt.Errorf("could not Destroy G2: %w", err)}
+	}
+	log.Log(2602)
+	return nil
+}
+
+// ----------------------------------------------------------------------------
+
+// AddRecord adds, or replaces, a record.
+func (engine *G2) AddRecord(ctx context.Context, dataSourceCode, recordID, jsonData string) error {
+	if engine.infoWriter == nil {
+		return engine.g2engine.AddRecord(ctx, dataSourceCode, recordID, jsonData, loadID)
+	}
+	withInfo, err := engine.g2engine.AddRecordWithInfo(ctx, dataSourceCode, recordID, jsonData, loadID, 0)
+	return engine.writeInfo(ctx, withInfo, err)
+}
+
+// ----------------------------------------------------------------------------
+
+// DeleteRecord deletes a record.
+func (engine *G2) DeleteRecord(ctx context.Context, dataSourceCode, recordID string) error {
+	if engine.infoWriter == nil {
+		return engine.g2engine.DeleteRecord(ctx, dataSourceCode, recordID, loadID)
+	}
+	withInfo, err := engine.g2engine.DeleteRecordWithInfo(ctx, dataSourceCode, recordID, loadID, 0)
+	return engine.writeInfo(ctx, withInfo, err)
+}
+
+// ----------------------------------------------------------------------------
+
+// ProcessRedoRecord processes the next redo record, returning it, or an empty
+// string when there are none.
+func (engine *G2) ProcessRedoRecord(ctx context.Context) (string, error) {
+	if engine.infoWriter == nil {
+		return engine.g2engine.ProcessRedoRecord(ctx)
+	}
+	redoRecord, withInfo, err := engine.g2engine.ProcessRedoRecordWithInfo(ctx, 0)
+	if len(redoRecord) == 0 {
+		return redoRecord, err
+	}
+	return redoRecord, engine.writeInfo(ctx, withInfo, err)
+}
+
+// ----------------------------------------------------------------------------
+
+// ReevaluateRecord reevaluates the entity resolution of a record.
+func (engine *G2) ReevaluateRecord(ctx context.Context, dataSourceCode, recordID string) error {
+	if engine.infoWriter == nil {
+		return engine.g2engine.ReevaluateRecord(ctx, dataSourceCode, recordID, 0)
+	}
+	withInfo, err := engine.g2engine.ReevaluateRecordWithInfo(ctx, dataSourceCode, recordID, 0)
+	return engine.writeInfo(ctx, withInfo, err)
+}
+
+// ----------------------------------------------------------------------------
+
+// Stats returns the engine's workload statistics as JSON.
+func (engine *G2) Stats(ctx context.Context) (string, error) {
+	return engine.g2engine.Stats(ctx)
+}
+
+// ----------------------------------------------------------------------------
+
+// write the with-info result of a successful call.  the call is only reported
+// as done once its result has been written, so a failed write leaves the
+// record to be retried.
+func (engine *G2) writeInfo(ctx context.Context, withInfo string, err error) error {
+	if err != nil {
+		return err
+	}
+	return engine.infoWriter.Write(ctx, withInfo)
+}
+
+// ----------------------------------------------------------------------------
+
+// the host:port to dial for a Senzing gRPC URL, eg. grpc://localhost:8258; an
+// empty URL gives an empty target.
+func grpcTarget(grpcURL string) (string, error) {
+	if len(grpcURL) == 0 {
+		return "", nil
+	}
+	u, err := url.Parse(grpcURL)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse the gRPC URL: %w", redact.Error(err))
+	}
+	if u.Scheme != "grpc" {
+		return "", fmt.Errorf("unsupported gRPC URL scheme %q, expected grpc://<host>:<port>", u.Scheme)
+	}
+	if len(u.Port()) == 0 || len(u.Hostname()) == 0 {
+		return "", fmt.Errorf("the gRPC URL %s needs a host and port, eg. grpc://localhost:8258", redact.URL(grpcURL))
+	}
+	return u.Host, nil
+}
@@ -0,0 +1,141 @@
+package engine
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+
+	g2enginepb "github.com/senzing/g2-sdk-proto/go/g2engine"
+	"google.golang.org/grpc"
+)
+
+// ----------------------------------------------------------------------------
+// Test helpers
+// ----------------------------------------------------------------------------
+
+// a stand-in Senzing gRPC server that records which records were added and
+// whether it was destroyed.
+type fakeEngineServer struct {
+	g2enginepb.UnimplementedG2EngineServer
+	added     sync.Map
+	destroyed bool
+}
+
+func (server *fakeEngineServer) AddRecord(ctx context.Context, request *g2enginepb.AddRecordRequest) (*g2enginepb.AddRecordResponse, error) {
+	server.added.Store(request.DataSourceCode+":"+request.RecordID, request.JsonData)
+	return &g2enginepb.AddRecordResponse{}, nil
+}
+
+func (server *fakeEngineServer) AddRecordWithInfo(ctx context.Context, request *g2enginepb.AddRecordWithInfoRequest) (*g2enginepb.AddRecordWithInfoResponse, error) {
+	server.added.Store(request.DataSourceCode+":"+request.RecordID, request.JsonData)
+	return &g2enginepb.AddRecordWithInfoResponse{Result: `{"RECORD_ID": "` + request.RecordID + `"}`}, nil
+}
+
+func (server *fakeEngineServer) Destroy(ctx context.Context, request *g2enginepb.DestroyRequest) (*g2enginepb.DestroyResponse, error) {
+	server.destroyed = true
+	return &g2enginepb.DestroyResponse{}, nil
+}
+
+func (server *fakeEngineServer) GetActiveConfigID(ctx context.Context, request *g2enginepb.GetActiveConfigIDRequest) (*g2enginepb.GetActiveConfigIDResponse, error) {
+	return &g2enginepb.GetActiveConfigIDResponse{Result: 1}, nil
+}
+
+// serve the stand-in on a local port, returning its gRPC URL
+func serveFakeEngine(test *testing.T, engineServer *fakeEngineServer) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		test.Fatal(err)
+	}
+	server := grpc.NewServer()
+	g2enginepb.RegisterG2EngineServer(server, engineServer)
+	go server.Serve(listener)
+	test.Cleanup(server.Stop)
+	return "grpc://" + listener.Addr().String()
+}
+
+// a writer that keeps the messages written to it
+type fakeWriter struct {
+	messages []string
+}
+
+func (writer *fakeWriter) Close() error { return nil }
+
+func (writer *fakeWriter) Write(ctx context.Context, message string) error {
+	writer.messages = append(writer.messages, message)
+	return nil
+}
+
+// ----------------------------------------------------------------------------
+// Test interface functions
+// ----------------------------------------------------------------------------
+
+func TestNew_grpc(test *testing.T) {
+	ctx := context.TODO()
+	engineServer := &fakeEngineServer{}
+	engine, err := New(ctx, serveFakeEngine(test, engineServer), "", 0, nil)
+	if err != nil {
+		test.Fatal(err)
+	}
+	if err := engine.AddRecord(ctx, "TEST", "1", `{"DATA_SOURCE": "TEST", "RECORD_ID": "1"}`); err != nil {
+		test.Fatal(err)
+	}
+	if _, ok := engineServer.added.Load("TEST:1"); !ok {
+		test.Error("expected the record to be added through gRPC")
+	}
+	if err := engine.Destroy(ctx); err != nil {
+		test.Fatal(err)
+	}
+	if engineServer.destroyed {
+		test.Error("expected the remote engine to be left running")
+	}
+}
+
+func TestNew_withInfo(test *testing.T) {
+	ctx := context.TODO()
+	writer := &fakeWriter{}
+	engine, err := New(ctx, serveFakeEngine(test, &fakeEngineServer{}), "", 0, writer)
+	if err != nil {
+		test.Fatal(err)
+	}
+	if err := engine.AddRecord(ctx, "TEST", "1", `{"DATA_SOURCE": "TEST", "RECORD_ID": "1"}`); err != nil {
+		test.Fatal(err)
+	}
+	if len(writer.messages) != 1 || writer.messages[0] != `{"RECORD_ID": "1"}` {
+		test.Errorf("expected the with-info result to be written, got %v", writer.messages)
+	}
+}
+
+func TestNew_unreachable(test *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		test.Fatal(err)
+	}
+	grpcURL := "grpc://" + listener.Addr().String()
+	listener.Close()
+	if _, err := New(context.TODO(), grpcURL, "", 0, nil); err == nil {
+		test.Error("expected an error for an unreachable gRPC server")
+	}
+}
+
+func TestGrpcTarget(test *testing.T) {
+	testCases := map[string]string{
+		"":                      "",
+		"grpc://localhost:8258": "localhost:8258",
+		"grpc://10.0.0.5:8258/": "10.0.0.5:8258",
+	}
+	for grpcURL, expected := range testCases {
+		target, err := grpcTarget(grpcURL)
+		if err != nil {
+			test.Errorf("%q: %v", grpcURL, err)
+		}
+		if target != expected {
+			test.Errorf("%q: expected %q, got %q", grpcURL, expected, target)
+		}
+	}
+	for _, grpcURL := range []string{"http://localhost:8258", "grpc://localhost", "localhost:8258"} {
+		if _, err := grpcTarget(grpcURL); err == nil {
+			test.Errorf("%q: expected an error", grpcURL)
+		}
+	}
+}
@@ -0,0 +1,7 @@
+/*
+The health package tracks whether load is alive and ready, for container
+orchestration probes.  inputs report the state of the engine and of their own
+broker or queue connection as it changes, and workers report the records they
+are working on, so probes never open connections of their own.
+*/
+package health
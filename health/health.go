@@ -0,0 +1,108 @@
+package health
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// a worker busy with a single record for longer than this is taken to be
+// stuck, eg. deadlocked in an engine call
+var StuckAfter = 5 * time.Minute
+
+var state = &status{lastProgress: time.Now()}
+
+// ----------------------------------------------------------------------------
+// Types
+// ----------------------------------------------------------------------------
+
+type status struct {
+	lock sync.Mutex
+
+	engineReady bool
+	// why the input is not ready; "" once it is
+	inputProblem string
+	inputReady   bool
+
+	busyWorkers  int
+	lastProgress time.Time
+}
+
+// ----------------------------------------------------------------------------
+
+// EngineReady records whether the engine is initialized.  a dry run has no
+// engine and reports it ready.
+func EngineReady(ready bool) {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+	state.engineReady = ready
+}
+
+// InputReady records that the input is connected and reading.
+func InputReady() {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+	state.inputReady = true
+	state.inputProblem = ""
+}
+
+// InputNotReady records why the input cannot read, eg. the broker
+// connection was lost and is being re-established.
+func InputNotReady(problem string) {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+	state.inputReady = false
+	state.inputProblem = problem
+}
+
+// ----------------------------------------------------------------------------
+
+// WorkerBusy records that a worker has started on a record; call the
+// returned function once it is done with it.
+func WorkerBusy() func() {
+	state.lock.Lock()
+	if state.busyWorkers == 0 {
+		state.lastProgress = time.Now()
+	}
+	state.busyWorkers++
+	state.lock.Unlock()
+	return func() {
+		state.lock.Lock()
+		defer state.lock.Unlock()
+		state.busyWorkers--
+		state.lastProgress = time.Now()
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+// Live returns an error when the workers appear to be stuck; no record has
+// been finished for StuckAfter while workers are busy.
+func Live() error {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+	if state.busyWorkers > 0 {
+		if stalled := time.Since(state.lastProgress); stalled > StuckAfter {
+			return fmt.Errorf("%d workers busy and no record finished for %v", state.busyWorkers, stalled.Round(time.Second))
+		}
+	}
+	return nil
+}
+
+// Ready returns an error when the engine is not initialized or the input
+// cannot read.
+func Ready() error {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+	if !state.engineReady {
+		return errors.New("the engine is not initialized")
+	}
+	if !state.inputReady {
+		if len(state.inputProblem) > 0 {
+			return errors.New(state.inputProblem)
+		}
+		return errors.New("the input is not connected")
+	}
+	return nil
+}
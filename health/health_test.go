@@ -0,0 +1,47 @@
+package health
+
+import (
+	"testing"
+	"time"
+)
+
+// ----------------------------------------------------------------------------
+// Test interface functions
+// ----------------------------------------------------------------------------
+
+func TestReady(test *testing.T) {
+	EngineReady(false)
+	InputNotReady("")
+	if Ready() == nil {
+		test.Error("expected not ready before the engine is initialized")
+	}
+	EngineReady(true)
+	InputNotReady("reconnecting to RabbitMQ")
+	if err := Ready(); err == nil || err.Error() != "reconnecting to RabbitMQ" {
+		test.Errorf("expected the input problem, got %v", err)
+	}
+	InputReady()
+	if err := Ready(); err != nil {
+		test.Errorf("expected ready, got %v", err)
+	}
+}
+
+func TestLive(test *testing.T) {
+	defer func(stuckAfter time.Duration) { StuckAfter = stuckAfter }(StuckAfter)
+	StuckAfter = 20 * time.Millisecond
+
+	WorkerBusy()()
+	time.Sleep(30 * time.Millisecond)
+	if err := Live(); err != nil {
+		test.Errorf("expected idle workers to be live, got %v", err)
+	}
+	done := WorkerBusy()
+	time.Sleep(30 * time.Millisecond)
+	if Live() == nil {
+		test.Error("expected a stuck worker to fail liveness")
+	}
+	done()
+	if err := Live(); err != nil {
+		test.Errorf("expected live once the worker finished, got %v", err)
+	}
+}
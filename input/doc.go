@@ -0,0 +1,10 @@
+/*
+The input package reads records from the input URL and hands them to a pool
+of workers that validate them and send them to the engine.  each input
+package, eg. input/file, registers the URL schemes it reads with Register
+when it is imported, and implements the Input interface; opening the source,
+streaming its records, acknowledging or requeueing each one once it has been
+processed and closing the source.  programs embedding load can register
+inputs of their own in the same way.
+*/
+package input
@@ -0,0 +1,135 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// supported compression formats
+const (
+	compressionNone  = ""
+	compressionBzip2 = "bzip2"
+	compressionGzip  = "gzip"
+	compressionZstd  = "zstd"
+)
+
+// the leading bytes that identify each compression format
+var magicBytes = map[string][]byte{
+	compressionBzip2: []byte("BZh"),
+	compressionGzip:  {0x1f, 0x8b},
+	compressionZstd:  {0x28, 0xb5, 0x2f, 0xfd},
+}
+
+// ----------------------------------------------------------------------------
+// Types
+// ----------------------------------------------------------------------------
+
+// a decompressing reader that closes both the decompressor and the
+// underlying file or response body
+type decompressReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *decompressReader) Close() error {
+	var err error
+	for _, closer := range r.closers {
+		if closeErr := closer.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// ----------------------------------------------------------------------------
+
+// wrap the given reader so that gzip, bzip2 and zstd content is decompressed
+// on the fly.  the file extension and Content-Encoding (when read over HTTP)
+// are used as hints, but the leading magic bytes of the content decide;
+// eg. a ".gz" resource that the HTTP client has already decompressed is read
+// as is.
+func decompress(reader io.ReadCloser, fileName, contentEncoding string) (io.ReadCloser, error) {
+	bufferedReader := bufio.NewReader(reader)
+	header, err := bufferedReader.Peek(4)
+	if err != nil && err != io.EOF {
+		reader.Close()
+		return nil, err
+	}
+
+	hint := compressionHint(fileName, contentEncoding)
+	compression := detectCompression(header)
+	if hint != compression {
+		log.Log(3301, "file", fileName, "expected", hint, "found", compression)
+	}
+
+	result := &decompressReader{closers: []io.Closer{reader}}
+	switch compression {
+	case compressionBzip2:
+		result.Reader = bzip2.NewReader(bufferedReader)
+	case compressionGzip:
+		gzipReader, err := gzip.NewReader(bufferedReader)
+		if err != nil {
+			reader.Close()
+			return nil, err
+		}
+		result.Reader = gzipReader
+		result.closers = append([]io.Closer{gzipReader}, result.closers...)
+	case compressionZstd:
+		zstdReader, err := zstd.NewReader(bufferedReader)
+		if err != nil {
+			reader.Close()
+			return nil, err
+		}
+		result.Reader = zstdReader
+		result.closers = append([]io.Closer{zstdReader.IOReadCloser()}, result.closers...)
+	default:
+		result.Reader = bufferedReader
+	}
+	if compression != compressionNone {
+		log.Log(2307, "file", fileName, "compression", compression)
+	}
+	return result, nil
+}
+
+// ----------------------------------------------------------------------------
+
+// determine the compression format from the leading bytes of the content
+func detectCompression(header []byte) string {
+	for compression, magic := range magicBytes {
+		if bytes.HasPrefix(header, magic) {
+			return compression
+		}
+	}
+	return compressionNone
+}
+
+// ----------------------------------------------------------------------------
+
+// determine the expected compression format from the HTTP Content-Encoding
+// or, failing that, the file extension
+func compressionHint(fileName, contentEncoding string) string {
+	switch strings.ToLower(strings.TrimSpace(contentEncoding)) {
+	case "gzip", "x-gzip":
+		return compressionGzip
+	case "bzip2", "x-bzip2":
+		return compressionBzip2
+	case "zstd":
+		return compressionZstd
+	}
+	name := strings.ToLower(fileName)
+	switch {
+	case strings.HasSuffix(name, ".gz"), strings.HasSuffix(name, ".gzip"):
+		return compressionGzip
+	case strings.HasSuffix(name, ".bz2"), strings.HasSuffix(name, ".bzip2"):
+		return compressionBzip2
+	case strings.HasSuffix(name, ".zst"), strings.HasSuffix(name, ".zstd"):
+		return compressionZstd
+	}
+	return compressionNone
+}
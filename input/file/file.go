@@ -0,0 +1,262 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/roncewind/load/input"
+	"github.com/roncewind/load/loaderror"
+	"github.com/roncewind/load/logging"
+	"github.com/roncewind/load/redact"
+)
+
+var log = logging.New("input/file", map[int]string{
+	2301: "Reading",
+	2302: "Detected the file type",
+	2303: "Finished reading",
+	2304: "Started reading the file",
+	2305: "Stopped reading the file",
+	2306: "Reached the end of the file",
+	2307: "Decompressing",
+	3301: "The content's compression differs from its name or Content-Encoding",
+})
+
+// supported file types, see --input-file-type
+const (
+	FileTypeCSV   = "CSV"
+	FileTypeJSONL = "JSONL"
+	FileTypeTSV   = "TSV"
+)
+
+// the records of a file cannot be read again
+var errNoRequeue = errors.New("records read from a file cannot be requeued")
+
+func init() {
+	for _, scheme := range []string{"file", "http", "https"} {
+		input.Register(input.Scheme{Name: scheme, New: New})
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Types
+// ----------------------------------------------------------------------------
+
+// an input that reads records from a file, or HTTP(S) resource
+type fileInput struct {
+	file     io.ReadCloser
+	fileType string
+	mapping  input.CSVMapping
+	name     string
+	reader   recordReader
+	u        *url.URL
+}
+
+// ----------------------------------------------------------------------------
+
+// New returns an input for the records in a file, or HTTP(S) resource.  the
+// file type is detected when not given and delimited files are mapped to
+// records with the options' mapping.
+func New(u *url.URL, options input.Options) (input.Input, error) {
+	if u.Scheme == "file" && len(u.Path) == 0 {
+		return nil, loaderror.ConfigurationError{Err: fmt.Errorf("the file URL %s has no path", redact.URL(u.String()))}
+	}
+	return &fileInput{
+		fileType: options.FileType,
+		mapping:  options.CSVMapping,
+		name:     fileName(u),
+		u:        u,
+	}, nil
+}
+
+// ----------------------------------------------------------------------------
+
+// Open the file and detect its type.
+func (in *fileInput) Open(ctx context.Context) error {
+	log.Log(2301, "url", redact.URL(in.u.String()))
+	file, err := open(ctx, in.u)
+	if err != nil {
+		return loaderror.ConnectionError{Err: fmt.Errorf("unable to open the file: %w", redact.Error(err))}
+	}
+
+	reader := bufio.NewReader(file)
+	fileType, err := detectFileType(in.fileType, in.u.Path, reader)
+	if err != nil {
+		file.Close()
+		return loaderror.ConfigurationError{Err: fmt.Errorf("unable to determine the file type: %w", err)}
+	}
+	log.Log(2302, "fileType", fileType)
+
+	in.file = file
+	switch fileType {
+	case FileTypeCSV:
+		in.reader = newCSVReader(reader, ',', in.mapping)
+	case FileTypeTSV:
+		in.reader = newCSVReader(reader, '\t', in.mapping)
+	default:
+		in.reader = newJSONLReader(reader)
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------------------
+
+// Stream each record in the file until the end of the file or the read
+// context is done, which is checked between records; the file is only closed
+// by Close, as decompressing readers cannot be closed while they are read.
+// an error is returned when the file cannot be read to the end.
+func (in *fileInput) Stream(readCtx context.Context, records chan<- *input.Record) error {
+	log.Log(2304, "file", in.name)
+	lineNumber := 0
+	defer func() {
+		log.Log(2303, "file", in.name, "lines", lineNumber)
+	}()
+	for {
+		currentLine, str, readErr := in.reader.next()
+		if readErr == io.EOF {
+			log.Log(2306, "file", in.name)
+			return nil
+		}
+		lineNumber = currentLine
+		if readCtx.Err() != nil {
+			log.Log(2305, "file", in.name, "line", lineNumber)
+			return nil
+		}
+		record := &input.Record{
+			Body:   str,
+			Source: fmt.Sprintf("%s:%d", in.name, currentLine),
+		}
+		if readErr != nil {
+			invalid, ok := readErr.(recordError)
+			if !ok {
+				return loaderror.InputError{Err: fmt.Errorf("unable to read %s at line %d: %w", in.name, currentLine, readErr)}
+			}
+			record.Body = invalid.raw
+			record.Err = readErr
+		}
+		select {
+		case records <- record:
+		case <-readCtx.Done():
+			log.Log(2305, "file", in.name, "line", lineNumber)
+			return nil
+		}
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+// Ack does nothing; the file is only read.
+func (in *fileInput) Ack(ctx context.Context, record *input.Record) error {
+	return nil
+}
+
+// ----------------------------------------------------------------------------
+
+// Nack cannot requeue a record.  a file has no dead lettering of its own.
+func (in *fileInput) Nack(ctx context.Context, record *input.Record, requeue bool) error {
+	if requeue {
+		return errNoRequeue
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------------------
+
+// Close the file.
+func (in *fileInput) Close() error {
+	if in.file == nil {
+		return nil
+	}
+	return in.file.Close()
+}
+
+// ----------------------------------------------------------------------------
+
+// open the file, or HTTP(S) resource, the URL points to.  compressed content
+// is decompressed as it is read.
+func open(ctx context.Context, u *url.URL) (io.ReadCloser, error) {
+	switch u.Scheme {
+	case "http", "https":
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			return nil, err
+		}
+		if response.StatusCode != http.StatusOK {
+			response.Body.Close()
+			return nil, fmt.Errorf("unable to retrieve %s: %s", fileName(u), response.Status)
+		}
+		return decompress(response.Body, u.Path, response.Header.Get("Content-Encoding"))
+	default:
+		file, err := os.Open(u.Path)
+		if err != nil {
+			return nil, err
+		}
+		return decompress(file, u.Path, "")
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+// use the given file type or, when none is given, detect it from the file
+// extension or, failing that, the first character of the content.
+func detectFileType(fileType, path string, reader *bufio.Reader) (string, error) {
+	if len(fileType) > 0 {
+		fileType = strings.ToUpper(fileType)
+		switch fileType {
+		case FileTypeCSV, FileTypeJSONL, FileTypeTSV:
+			return fileType, nil
+		}
+		return "", fmt.Errorf("unsupported file type %q, expected one of %s, %s or %s", fileType, FileTypeCSV, FileTypeJSONL, FileTypeTSV)
+	}
+
+	name := strings.ToLower(path)
+	for _, extension := range []string{".gz", ".gzip", ".bz2", ".bzip2", ".zst", ".zstd"} {
+		name = strings.TrimSuffix(name, extension)
+	}
+	switch {
+	case strings.HasSuffix(name, ".csv"):
+		return FileTypeCSV, nil
+	case strings.HasSuffix(name, ".tsv"), strings.HasSuffix(name, ".tab"):
+		return FileTypeTSV, nil
+	case strings.HasSuffix(name, ".jsonl"), strings.HasSuffix(name, ".json"), strings.HasSuffix(name, ".ndjson"):
+		return FileTypeJSONL, nil
+	}
+
+	// JSON records start with a brace, anything else is taken as a header row
+	content, err := reader.Peek(512)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return "", err
+	}
+	content = bytes.TrimLeft(content, " \t\r\n\ufeff")
+	if len(content) == 0 || content[0] == '{' {
+		return FileTypeJSONL, nil
+	}
+	if bytes.Count(content, []byte("\t")) > bytes.Count(content, []byte(",")) {
+		return FileTypeTSV, nil
+	}
+	return FileTypeCSV, nil
+}
+
+// ----------------------------------------------------------------------------
+
+// the name used to identify the file in messages; local files use their path
+// and HTTP(S) resources use their URL without any query string
+func fileName(u *url.URL) string {
+	switch u.Scheme {
+	case "http", "https":
+		return u.Scheme + "://" + u.Host + u.Path
+	default:
+		return u.Path
+	}
+}
@@ -0,0 +1,281 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/roncewind/load/input"
+	"github.com/roncewind/load/loaderror"
+)
+
+// ----------------------------------------------------------------------------
+// Test helpers
+// ----------------------------------------------------------------------------
+
+// open a file input for the content, written to a file with the given name
+func openFile(test *testing.T, name, content string, options input.Options) input.Input {
+	name = filepath.Join(test.TempDir(), name)
+	if err := os.WriteFile(name, []byte(content), 0o600); err != nil {
+		test.Fatal(err)
+	}
+	u, _ := url.Parse("file://" + name)
+	in, err := New(u, options)
+	if err != nil {
+		test.Fatal(err)
+	}
+	if err := in.Open(context.TODO()); err != nil {
+		test.Fatal(err)
+	}
+	test.Cleanup(func() { in.Close() })
+	return in
+}
+
+// the records streamed from the input
+func stream(test *testing.T, readCtx context.Context, in input.Input) []*input.Record {
+	records := make(chan *input.Record)
+	streamErr := make(chan error, 1)
+	go func() {
+		defer close(records)
+		streamErr <- in.Stream(readCtx, records)
+	}()
+	var streamed []*input.Record
+	for record := range records {
+		streamed = append(streamed, record)
+	}
+	if err := <-streamErr; err != nil {
+		test.Fatal(err)
+	}
+	return streamed
+}
+
+// ----------------------------------------------------------------------------
+// Test interface functions
+// ----------------------------------------------------------------------------
+
+func TestStream_JSONL(test *testing.T) {
+	lines := []string{
+		`{"DATA_SOURCE": "TEST", "RECORD_ID": "1"}`,
+		``,
+		`{"DATA_SOURCE": "TEST", "RECORD_ID": "2"}`,
+		`not json`,
+		`{"DATA_SOURCE": "TEST", "RECORD_ID": "3"}`,
+	}
+	in := openFile(test, "records.jsonl", strings.Join(lines, "\n"), input.Options{})
+	records := stream(test, context.TODO(), in)
+
+	if len(records) != 4 {
+		test.Fatalf("expected 4 records, got %d", len(records))
+	}
+	if records[1].Body != lines[2] || !strings.HasSuffix(records[1].Source, "records.jsonl:3") {
+		test.Errorf("expected the record on line 3, got %+v", records[1])
+	}
+	if records[2].Body != "not json" {
+		test.Errorf("expected invalid records to be streamed as they are, got %+v", records[2])
+	}
+	if err := in.Nack(context.TODO(), records[0], true); err == nil {
+		test.Error("expected records read from a file not to be requeued")
+	}
+}
+
+func TestStream_cancel(test *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	in := openFile(test, "records.jsonl", `{"DATA_SOURCE": "TEST", "RECORD_ID": "1"}`, input.Options{})
+	if records := stream(test, ctx, in); len(records) != 0 {
+		test.Errorf("expected no records read after cancel, got %d", len(records))
+	}
+}
+
+func TestStream_cancelCompressed(test *testing.T) {
+	var gzipped bytes.Buffer
+	gzipWriter := gzip.NewWriter(&gzipped)
+	for i := 0; i < 1000; i++ {
+		gzipWriter.Write([]byte(`{"DATA_SOURCE": "TEST", "RECORD_ID": "1"}` + "\n"))
+	}
+	gzipWriter.Close()
+	in := openFile(test, "records.jsonl.gz", gzipped.String(), input.Options{})
+
+	// stop reading part way through; the file is closed once, by Close
+	readCtx, cancel := context.WithCancel(context.TODO())
+	records := make(chan *input.Record)
+	streamErr := make(chan error, 1)
+	go func() { streamErr <- in.Stream(readCtx, records) }()
+	<-records
+	cancel()
+	if err := <-streamErr; err != nil {
+		test.Fatal(err)
+	}
+	if err := in.Close(); err != nil {
+		test.Errorf("expected the file to be closed, got %v", err)
+	}
+}
+
+func TestDecompress(test *testing.T) {
+	content := `{"DATA_SOURCE": "TEST", "RECORD_ID": "1"}` + "\n"
+
+	var gzipped bytes.Buffer
+	gzipWriter := gzip.NewWriter(&gzipped)
+	gzipWriter.Write([]byte(content))
+	gzipWriter.Close()
+
+	var zstded bytes.Buffer
+	zstdWriter, _ := zstd.NewWriter(&zstded)
+	zstdWriter.Write([]byte(content))
+	zstdWriter.Close()
+
+	testCases := []struct {
+		name            string
+		fileName        string
+		contentEncoding string
+		content         []byte
+	}{
+		{"plain", "test.jsonl", "", []byte(content)},
+		{"gzip by extension", "test.jsonl.gz", "", gzipped.Bytes()},
+		{"gzip by content encoding", "test.jsonl", "gzip", gzipped.Bytes()},
+		{"gzip by magic bytes", "test.jsonl", "", gzipped.Bytes()},
+		{"zstd by extension", "test.jsonl.zst", "", zstded.Bytes()},
+		{"zstd by magic bytes", "test", "", zstded.Bytes()},
+		{"already decompressed", "test.jsonl.gz", "", []byte(content)},
+	}
+	for _, testCase := range testCases {
+		test.Run(testCase.name, func(test *testing.T) {
+			reader, err := decompress(io.NopCloser(bytes.NewReader(testCase.content)), testCase.fileName, testCase.contentEncoding)
+			if err != nil {
+				test.Fatal(err)
+			}
+			defer reader.Close()
+			actual, err := io.ReadAll(reader)
+			if err != nil {
+				test.Fatal(err)
+			}
+			if string(actual) != content {
+				test.Errorf("expected %q, got %q", content, actual)
+			}
+		})
+	}
+}
+
+func TestStream_truncated(test *testing.T) {
+	var gzipped bytes.Buffer
+	gzipWriter := gzip.NewWriter(&gzipped)
+	for i := 0; i < 1000; i++ {
+		gzipWriter.Write([]byte(`{"DATA_SOURCE": "TEST", "RECORD_ID": "1"}` + "\n"))
+	}
+	gzipWriter.Close()
+	in := openFile(test, "records.jsonl.gz", gzipped.String()[:gzipped.Len()/2], input.Options{})
+
+	// the line cut short is not a record; reading stops with an input error
+	records := make(chan *input.Record)
+	streamErr := make(chan error, 1)
+	go func() {
+		defer close(records)
+		streamErr <- in.Stream(context.TODO(), records)
+	}()
+	for record := range records {
+		if record.Body != `{"DATA_SOURCE": "TEST", "RECORD_ID": "1"}` {
+			test.Errorf("expected only whole records, got %q", record.Body)
+		}
+	}
+	var inputError loaderror.InputError
+	if err := <-streamErr; !errors.As(err, &inputError) {
+		test.Errorf("expected an input error, got %v", err)
+	}
+}
+
+func TestStream_CSV(test *testing.T) {
+	lines := []string{
+		"\ufeffCUSTOMER_NUMBER,NAME_FULL, EMAIL_ADDRESS",
+		"1001,Robert Smith,bob@example.com",
+		`1002,"Smith, Jane",`,
+		"1003,Only two",
+		"1004,Mary Jones,mary@example.com",
+	}
+	options := input.Options{
+		CSVMapping: input.CSVMapping{
+			DataSource:     "CUSTOMERS",
+			RecordIDColumn: "CUSTOMER_NUMBER",
+		},
+	}
+	in := openFile(test, "customers.csv", strings.Join(lines, "\n"), options)
+	records := stream(test, context.TODO(), in)
+
+	if len(records) != 4 {
+		test.Fatalf("expected 4 records, got %d", len(records))
+	}
+	expected := `{"CUSTOMER_NUMBER":"1002","DATA_SOURCE":"CUSTOMERS","NAME_FULL":"Smith, Jane","RECORD_ID":"1002"}`
+	if records[1].Body != expected {
+		test.Errorf("expected %s, got %s", expected, records[1].Body)
+	}
+	if records[2].Err == nil || records[2].Body != "1003,Only two" {
+		test.Errorf("expected the short row to be an error, got %+v", records[2])
+	}
+}
+
+func TestCSVReader_parseError(test *testing.T) {
+	reader := newCSVReader(strings.NewReader("ID,NAME\n1,\"Smith\" Jane\n2,Jones\n"), ',', input.CSVMapping{})
+	reader.reader.LazyQuotes = false
+
+	// the row that cannot be parsed is kept for dead lettering
+	lineNumber, _, err := reader.next()
+	invalid, ok := err.(recordError)
+	if !ok || lineNumber != 2 || invalid.raw != `1,"Smith" Jane` {
+		test.Fatalf("next() = %d, %v, expected line 2 and the row as parsed", lineNumber, err)
+	}
+	if lineNumber, record, err := reader.next(); err != nil || lineNumber != 3 || record != `{"ID":"2","NAME":"Jones"}` {
+		test.Errorf("next() = %d, %s, %v, expected the next row", lineNumber, record, err)
+	}
+}
+
+func TestDetectFileType(test *testing.T) {
+	testCases := []struct {
+		fileType string
+		path     string
+		content  string
+		expected string
+	}{
+		{"", "/data/test.jsonl", "", FileTypeJSONL},
+		{"", "/data/test.csv.gz", "", FileTypeCSV},
+		{"", "/data/test.tsv", "", FileTypeTSV},
+		{"csv", "/data/test.jsonl", "", FileTypeCSV},
+		{"", "/data/test", `  {"DATA_SOURCE": "TEST"}`, FileTypeJSONL},
+		{"", "/data/test", "NAME_FULL,EMAIL_ADDRESS", FileTypeCSV},
+		{"", "/data/test", "NAME_FULL\tEMAIL_ADDRESS", FileTypeTSV},
+	}
+	for _, testCase := range testCases {
+		actual, err := detectFileType(testCase.fileType, testCase.path, bufio.NewReader(strings.NewReader(testCase.content)))
+		if err != nil {
+			test.Fatal(err)
+		}
+		if actual != testCase.expected {
+			test.Errorf("detectFileType(%q, %q) = %s, expected %s", testCase.fileType, testCase.path, actual, testCase.expected)
+		}
+	}
+	if _, err := detectFileType("xml", "/data/test.xml", bufio.NewReader(strings.NewReader(""))); err == nil {
+		test.Error("expected an error for an unsupported file type")
+	}
+}
+
+func TestNew(test *testing.T) {
+	u, _ := url.Parse("file://")
+	if _, err := New(u, input.Options{}); err == nil {
+		test.Error("expected an error for a file URL without a path")
+	}
+	u, _ = url.Parse("file:///no/such/file.jsonl")
+	in, err := New(u, input.Options{})
+	if err != nil {
+		test.Fatal(err)
+	}
+	if err := in.Open(context.TODO()); err == nil {
+		test.Error("expected an error opening a missing file")
+	}
+}
@@ -0,0 +1,209 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/roncewind/load/input"
+)
+
+// ----------------------------------------------------------------------------
+// Types
+// ----------------------------------------------------------------------------
+
+// a source of records read from a file one at a time
+type recordReader interface {
+	// the line the next record starts on and the record as a JSON string.
+	// io.EOF is returned at the end of the file and a recordError when only
+	// the current record is unusable.
+	next() (int, string, error)
+}
+
+// an error that only affects a single record; reading may continue
+type recordError struct {
+	error
+	// the record as it was read, for dead lettering
+	raw string
+}
+
+// ----------------------------------------------------------------------------
+// JSONL
+// ----------------------------------------------------------------------------
+
+// reads one JSON record per line, skipping blank lines
+type jsonlReader struct {
+	lineNumber int
+	reader     *bufio.Reader
+}
+
+func newJSONLReader(reader io.Reader) *jsonlReader {
+	return &jsonlReader{reader: bufio.NewReader(reader)}
+}
+
+func (r *jsonlReader) next() (int, string, error) {
+	for {
+		line, err := r.reader.ReadString('\n')
+		r.lineNumber++
+		if err != nil && err != io.EOF {
+			// the line was cut short, eg. by a truncated gzip stream; it is
+			// not a record
+			return r.lineNumber, "", err
+		}
+		str := strings.TrimSpace(line)
+		// ignore blank lines
+		if len(str) > 0 {
+			// a final line without a newline is still a record
+			return r.lineNumber, str, nil
+		}
+		if err != nil {
+			return r.lineNumber, "", err
+		}
+	}
+}
+
+// ----------------------------------------------------------------------------
+// CSV
+// ----------------------------------------------------------------------------
+
+// reads delimited rows, converting each to a JSON record using the header row
+type csvReader struct {
+	header   []string
+	mapping  input.CSVMapping
+	reader   *csv.Reader
+	recorder *lineRecorder
+}
+
+// remembers the lines read from the start of the row being parsed, so that a
+// row that cannot be parsed can be dead lettered as it was
+type lineRecorder struct {
+	// the number of the first line in text
+	firstLine int
+	reader    io.Reader
+	text      []byte
+}
+
+func newCSVReader(reader io.Reader, comma rune, mapping input.CSVMapping) *csvReader {
+	recorder := &lineRecorder{firstLine: 1, reader: reader}
+	delimitedReader := csv.NewReader(recorder)
+	delimitedReader.Comma = comma
+	delimitedReader.FieldsPerRecord = -1
+	delimitedReader.LazyQuotes = true
+	delimitedReader.TrimLeadingSpace = true
+	return &csvReader{
+		mapping:  mapping,
+		reader:   delimitedReader,
+		recorder: recorder,
+	}
+}
+
+func (r *csvReader) next() (int, string, error) {
+	if r.header == nil {
+		header, err := r.reader.Read()
+		if err != nil {
+			return 1, "", err
+		}
+		for i, name := range header {
+			header[i] = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		}
+		r.header = header
+	}
+
+	row, err := r.reader.Read()
+	if err != nil {
+		if parseErr, ok := err.(*csv.ParseError); ok {
+			raw := r.recorder.lines(parseErr.StartLine, parseErr.Line)
+			r.recorder.forget(parseErr.Line)
+			return parseErr.StartLine, "", recordError{parseErr, raw}
+		}
+		return 0, "", err
+	}
+	lineNumber, _ := r.reader.FieldPos(0)
+	r.recorder.forget(lineNumber)
+	if len(row) != len(r.header) {
+		return lineNumber, "", recordError{fmt.Errorf("expected %d columns, found %d", len(r.header), len(row)), r.raw(row)}
+	}
+	record, err := r.toJSON(row)
+	if err != nil {
+		return lineNumber, "", recordError{err, r.raw(row)}
+	}
+	return lineNumber, record, nil
+}
+
+// ----------------------------------------------------------------------------
+
+// the row as a delimited line, for reporting
+func (r *csvReader) raw(row []string) string {
+	var line strings.Builder
+	writer := csv.NewWriter(&line)
+	writer.Comma = r.reader.Comma
+	writer.Write(row)
+	writer.Flush()
+	return strings.TrimSuffix(line.String(), "\n")
+}
+
+// ----------------------------------------------------------------------------
+
+func (r *lineRecorder) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.text = append(r.text, p[:n]...)
+	return n, err
+}
+
+// the text of the lines from first to last, without the last line's end
+func (r *lineRecorder) lines(first, last int) string {
+	start := skipLines(r.text, first-r.firstLine)
+	end := start + skipLines(r.text[start:], last-first+1)
+	return strings.TrimRight(string(r.text[start:end]), "\r\n")
+}
+
+// forget the lines before the given line
+func (r *lineRecorder) forget(line int) {
+	if line > r.firstLine {
+		r.text = r.text[skipLines(r.text, line-r.firstLine):]
+		r.firstLine = line
+	}
+}
+
+// the offset of the start of the text after n lines, or its length when it
+// has fewer
+func skipLines(text []byte, n int) int {
+	offset := 0
+	for ; n > 0; n-- {
+		i := bytes.IndexByte(text[offset:], '\n')
+		if i < 0 {
+			return len(text)
+		}
+		offset += i + 1
+	}
+	return offset
+}
+
+// ----------------------------------------------------------------------------
+
+// convert a row to a JSON record; empty values are left out and DATA_SOURCE
+// and RECORD_ID are filled in according to the mapping
+func (r *csvReader) toJSON(row []string) (string, error) {
+	attributes := make(map[string]string, len(row)+2)
+	for i, value := range row {
+		value = strings.TrimSpace(value)
+		if len(r.header[i]) > 0 && len(value) > 0 {
+			attributes[r.header[i]] = value
+		}
+	}
+	if len(r.mapping.DataSourceColumn) > 0 {
+		attributes["DATA_SOURCE"] = attributes[r.mapping.DataSourceColumn]
+	}
+	if len(attributes["DATA_SOURCE"]) == 0 && len(r.mapping.DataSource) > 0 {
+		attributes["DATA_SOURCE"] = r.mapping.DataSource
+	}
+	if len(r.mapping.RecordIDColumn) > 0 {
+		attributes["RECORD_ID"] = attributes[r.mapping.RecordIDColumn]
+	}
+	record, err := json.Marshal(attributes)
+	return string(record), err
+}
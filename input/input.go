@@ -0,0 +1,195 @@
+package input
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/roncewind/load/loaderror"
+	"github.com/roncewind/load/redact"
+)
+
+// the inputs registered for each URL scheme, see Register
+var registry = struct {
+	lock    sync.Mutex
+	schemes map[string][]Scheme
+}{schemes: map[string][]Scheme{}}
+
+// ----------------------------------------------------------------------------
+// Types
+// ----------------------------------------------------------------------------
+
+// Input is a source of records; a file, a queue or a stream.  Read opens it,
+// streams its records to the workers, settles each record once it has been
+// processed and closes it.
+type Input interface {
+	// Open connects to the source, or opens the file, ready to stream
+	// records.  ctx is the context the records are processed with.
+	Open(ctx context.Context) error
+	// Stream sends the records read from the source to the channel until the
+	// source is exhausted or the read context is done, and returns nil; or
+	// an error when the source cannot be read.
+	Stream(readCtx context.Context, records chan<- *Record) error
+	// Ack settles a record that was processed, or written to the dead letter
+	// output; it is not read again.
+	Ack(ctx context.Context, record *Record) error
+	// Nack returns a record to the source to be read again or, without
+	// requeue, hands it to the source's own dead lettering, if it has any;
+	// eg. a RabbitMQ dead letter exchange or an SQS redrive queue.  an error
+	// is returned when the record cannot be requeued.
+	Nack(ctx context.Context, record *Record, requeue bool) error
+	// Close disconnects from the source once the records streamed have been
+	// settled.
+	Close() error
+}
+
+// Record is a single record read from an input.
+type Record struct {
+	// Body is the record as it was read, for validation and dead lettering
+	Body string
+	// Err is set for a record that could not be read, eg. a malformed CSV
+	// row; it is rejected with the error
+	Err error
+	// Handle is the input's own reference to the record, for Ack and Nack
+	Handle interface{}
+	// Operation is named by the message's header or attribute, when it has
+	// one; see the operation package
+	Operation string
+	// Retry is set when a record the engine rejects should be requeued to be
+	// tried again rather than failed, eg. on its first delivery from a queue
+	Retry bool
+	// Source identifies the record in log messages and dead letters, eg. the
+	// file and line number, or the queue and message ID
+	Source string
+}
+
+// Options configure an input when it is created.
+type Options struct {
+	// CSVMapping maps the columns of delimited files to attributes
+	CSVMapping CSVMapping
+	// DryRun is set when records are only read and validated; they are
+	// never settled and should be left on their queue
+	DryRun bool
+	// FileType of files; detected when empty
+	FileType string
+	// NumberOfWorkers processing the records, eg. for a queue's prefetch
+	NumberOfWorkers int
+	// VisibilityPeriodInSeconds a queue message is hidden from other
+	// consumers while it is processed
+	VisibilityPeriodInSeconds int
+}
+
+// CSVMapping is how the columns of delimited files are mapped to Generic
+// Entity Specification attributes.  header names are used as attribute names
+// as is.
+type CSVMapping struct {
+	// DATA_SOURCE used for rows that do not carry one
+	DataSource string
+	// column to take each row's DATA_SOURCE from
+	DataSourceColumn string
+	// column to take each row's RECORD_ID from
+	RecordIDColumn string
+}
+
+// Scheme registers an input for the URLs with a scheme, see Register.
+type Scheme struct {
+	// Name of the URL scheme, eg. amqp
+	Name string
+	// Label records from the input are labelled with in metrics; the Name
+	// when empty
+	Label string
+	// Match reports whether a URL is for the input, when more than one input
+	// registers the scheme; nil matches the URLs no other input matches
+	Match func(u *url.URL) bool
+	// New returns the input for a URL
+	New func(u *url.URL, options Options) (Input, error)
+}
+
+// ----------------------------------------------------------------------------
+
+// Register makes an input available for the URLs with a scheme; input
+// packages register their schemes when they are imported.  when more than
+// one input registers the same scheme, eg. https for files and SQS queues,
+// each URL goes to the first input whose Match it is, or else to the one
+// without a Match.  Register panics if a scheme has two inputs without one.
+func Register(scheme Scheme) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	if scheme.Match == nil {
+		for _, registered := range registry.schemes[scheme.Name] {
+			if registered.Match == nil {
+				panic(fmt.Sprintf("input: scheme %s is registered twice", scheme.Name))
+			}
+		}
+	}
+	registry.schemes[scheme.Name] = append(registry.schemes[scheme.Name], scheme)
+}
+
+// ----------------------------------------------------------------------------
+
+// Schemes returns the URL schemes inputs are registered for, sorted.
+func Schemes() []string {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	names := make([]string, 0, len(registry.schemes))
+	for name := range registry.schemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ----------------------------------------------------------------------------
+
+// New returns the input registered for the URL, not yet opened.
+func New(inputURL string, options Options) (Input, error) {
+	u, err := url.Parse(inputURL)
+	if err != nil {
+		return nil, loaderror.ConfigurationError{Err: fmt.Errorf("unable to parse the input URL: %w", redact.Error(err))}
+	}
+	scheme, err := lookup(u)
+	if err != nil {
+		return nil, err
+	}
+	return scheme.New(u, options)
+}
+
+// ----------------------------------------------------------------------------
+
+// the input registered for the URL
+func lookup(u *url.URL) (Scheme, error) {
+	registry.lock.Lock()
+	registered := registry.schemes[u.Scheme]
+	registry.lock.Unlock()
+
+	var fallback *Scheme
+	for i, scheme := range registered {
+		if scheme.Match == nil {
+			fallback = &registered[i]
+			continue
+		}
+		if scheme.Match(u) {
+			return scheme, nil
+		}
+	}
+	if fallback != nil {
+		return *fallback, nil
+	}
+	if len(registered) > 0 {
+		return Scheme{}, loaderror.ConfigurationError{Err: fmt.Errorf("no input accepts the %s URL %s", u.Scheme, redact.URL(u.String()))}
+	}
+	return Scheme{}, loaderror.ConfigurationError{Err: fmt.Errorf("unsupported input URL scheme %q, expected one of %s", u.Scheme, strings.Join(Schemes(), ", "))}
+}
+
+// ----------------------------------------------------------------------------
+
+// the label for records from the input registered for the scheme
+func (scheme Scheme) label() string {
+	if len(scheme.Label) > 0 {
+		return scheme.Label
+	}
+	return scheme.Name
+}
@@ -0,0 +1,344 @@
+package input_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/roncewind/load/engine"
+	"github.com/roncewind/load/input"
+	"github.com/roncewind/load/loaderror"
+	"github.com/roncewind/load/metrics"
+
+	_ "github.com/roncewind/load/input/file"
+	_ "github.com/roncewind/load/input/kafka"
+	_ "github.com/roncewind/load/input/nats"
+	_ "github.com/roncewind/load/input/sqs"
+)
+
+// ----------------------------------------------------------------------------
+// Test helpers
+// ----------------------------------------------------------------------------
+
+// a stand-in engine that records which records were added, deleted and
+// reevaluated.  records with the RECORD_ID BAD are rejected.
+type fakeEngine struct {
+	engine.Engine
+	lock        sync.Mutex
+	order       []string
+	added       sync.Map
+	deleted     sync.Map
+	reevaluated sync.Map
+}
+
+func (engine *fakeEngine) AddRecord(ctx context.Context, dataSourceCode, recordID, jsonData string) error {
+	if recordID == "BAD" {
+		return errors.New("engine rejected the record")
+	}
+	engine.added.Store(dataSourceCode+":"+recordID, jsonData)
+	engine.lock.Lock()
+	defer engine.lock.Unlock()
+	engine.order = append(engine.order, dataSourceCode)
+	return nil
+}
+
+func (engine *fakeEngine) DeleteRecord(ctx context.Context, dataSourceCode, recordID string) error {
+	engine.deleted.Store(dataSourceCode+":"+recordID, true)
+	return nil
+}
+
+func (engine *fakeEngine) ReevaluateRecord(ctx context.Context, dataSourceCode, recordID string) error {
+	engine.reevaluated.Store(dataSourceCode+":"+recordID, true)
+	return nil
+}
+
+// the fake queues, by host, see fake:// URLs
+var fakeQueues = map[string]*fakeQueue{}
+
+func init() {
+	input.Register(input.Scheme{
+		Name: "fake",
+		New: func(u *url.URL, options input.Options) (input.Input, error) {
+			return fakeQueues[u.Host], nil
+		},
+	})
+}
+
+// a stand-in queue that streams its records once and remembers how each was
+// settled
+type fakeQueue struct {
+	records []*input.Record
+	lock    sync.Mutex
+	settled map[string]string
+}
+
+func (queue *fakeQueue) Open(ctx context.Context) error {
+	queue.settled = map[string]string{}
+	return nil
+}
+
+func (queue *fakeQueue) Stream(readCtx context.Context, records chan<- *input.Record) error {
+	for _, record := range queue.records {
+		records <- record
+	}
+	return nil
+}
+
+func (queue *fakeQueue) Ack(ctx context.Context, record *input.Record) error {
+	queue.settle(record, "ack")
+	return nil
+}
+
+func (queue *fakeQueue) Nack(ctx context.Context, record *input.Record, requeue bool) error {
+	if requeue {
+		queue.settle(record, "requeue")
+	} else {
+		queue.settle(record, "reject")
+	}
+	return nil
+}
+
+func (queue *fakeQueue) Close() error {
+	return nil
+}
+
+func (queue *fakeQueue) settle(record *input.Record, how string) {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	queue.settled[record.Source] = how
+}
+
+// a dead letter writer that cannot be written to
+type failingWriter struct{}
+
+func (writer failingWriter) Write(ctx context.Context, message string) error {
+	return errors.New("unreachable")
+}
+
+func (writer failingWriter) Close() error { return nil }
+
+// write the lines to a JSONL file, returning its URL
+func writeFile(test *testing.T, lines ...string) string {
+	name := filepath.Join(test.TempDir(), "records.jsonl")
+	if err := os.WriteFile(name, []byte(strings.Join(lines, "\n")), 0o600); err != nil {
+		test.Fatal(err)
+	}
+	return "file://" + name
+}
+
+// ----------------------------------------------------------------------------
+// Test interface functions
+// ----------------------------------------------------------------------------
+
+func TestRead(test *testing.T) {
+	ctx := context.TODO()
+	inputURL := writeFile(test,
+		`{"DATA_SOURCE": "TEST", "RECORD_ID": "1"}`,
+		``,
+		`{"DATA_SOURCE": "TEST", "RECORD_ID": "2"}`,
+		`{"DATA_SOURCE": "TEST"}`,
+		`{"DATA_SOURCE": "TEST", "RECORD_ID": "BAD"}`,
+		`not json`,
+		`{"DATA_SOURCE": "TEST", "RECORD_ID": "3"}`,
+	)
+	metrics.Reset()
+	engine := &fakeEngine{}
+	err := input.Read(ctx, ctx, input.ReadOptions{Engine: engine, InputURLs: []string{inputURL}, NumberOfWorkers: 2, ValidationMode: "lenient"})
+	if err != nil {
+		test.Fatal(err)
+	}
+	totals := metrics.Snapshot()
+	if totals.Read != 6 || totals.Loaded != 3 || totals.Failed != 3 {
+		test.Errorf("expected 6 records read, 3 loaded and 3 failed, got %+v", totals.Counts)
+	}
+	if validation := totals.Validation; validation == nil || validation.Checked != 6 || validation.Rejected != 2 || validation.Rules["record-id"] != 1 {
+		test.Errorf("expected 6 records checked and 2 rejected, got %+v", validation)
+	}
+	for _, key := range []string{"TEST:1", "TEST:2", "TEST:3"} {
+		if _, ok := engine.added.Load(key); !ok {
+			test.Errorf("expected %s to be added", key)
+		}
+	}
+}
+
+func TestRead_operations(test *testing.T) {
+	ctx := context.TODO()
+	inputURL := writeFile(test,
+		`{"DATA_SOURCE": "TEST", "RECORD_ID": "1", "LOAD_OPERATION": "add", "NAME_FULL": "A"}`,
+		`{"DATA_SOURCE": "TEST", "RECORD_ID": "2", "LOAD_OPERATION": "delete"}`,
+		`{"DATA_SOURCE": "TEST", "RECORD_ID": "3", "LOAD_OPERATION": "reevaluate"}`,
+		`{"DATA_SOURCE": "TEST", "RECORD_ID": "4", "LOAD_OPERATION": "upsert"}`,
+	)
+	metrics.Reset()
+	engine := &fakeEngine{}
+	err := input.Read(ctx, ctx, input.ReadOptions{Engine: engine, InputURLs: []string{inputURL}, NumberOfWorkers: 1, ValidationMode: "lenient"})
+	if err != nil {
+		test.Fatal(err)
+	}
+	if totals := metrics.Snapshot(); totals.Loaded != 3 || totals.Failed != 1 {
+		test.Errorf("expected 3 records processed and 1 failed, got %+v", totals.Counts)
+	}
+	if added, ok := engine.added.Load("TEST:1"); !ok || strings.Contains(added.(string), "LOAD_OPERATION") {
+		test.Errorf("expected TEST:1 to be added without its operation, got %v", added)
+	}
+	if _, ok := engine.deleted.Load("TEST:2"); !ok {
+		test.Error("expected TEST:2 to be deleted")
+	}
+	if _, ok := engine.reevaluated.Load("TEST:3"); !ok {
+		test.Error("expected TEST:3 to be reevaluated")
+	}
+}
+
+func TestRead_deadLetterFailed(test *testing.T) {
+	ctx := context.TODO()
+	inputURL := writeFile(test,
+		`{"DATA_SOURCE": "TEST", "RECORD_ID": "BAD"}`,
+		`{"DATA_SOURCE": "TEST", "RECORD_ID": "1"}`,
+	)
+	metrics.Reset()
+
+	// a record from a file cannot be requeued, so it would be lost
+	err := input.Read(ctx, ctx, input.ReadOptions{DeadLetterWriter: failingWriter{}, Engine: &fakeEngine{}, InputURLs: []string{inputURL}, NumberOfWorkers: 1, ValidationMode: "lenient"})
+	var connectionError loaderror.ConnectionError
+	if !errors.As(err, &connectionError) {
+		test.Errorf("expected a connection error, got %v", err)
+	}
+	if totals := metrics.Snapshot(); totals.Failed != 1 {
+		test.Errorf("expected the record to be counted as failed, got %+v", totals.Counts)
+	}
+}
+
+func TestRead_dryRun(test *testing.T) {
+	ctx := context.TODO()
+	inputURL := writeFile(test,
+		`{"DATA_SOURCE": "TEST", "RECORD_ID": "1"}`,
+		`{"DATA_SOURCE": "TEST"}`,
+		`{"DATA_SOURCE": "OTHER", "RECORD_ID": "BAD"}`,
+	)
+	metrics.Reset()
+	err := input.Read(ctx, ctx, input.ReadOptions{InputURLs: []string{inputURL}, NumberOfWorkers: 1, ValidationMode: "lenient"})
+	if err != nil {
+		test.Fatal(err)
+	}
+	totals := metrics.Snapshot()
+	if totals.Skipped != 2 || totals.Failed != 1 {
+		test.Errorf("expected 2 good and 1 bad record, got %+v", totals.Counts)
+	}
+	if totals.Validation == nil || totals.Validation.DataSources["TEST"].Bad != 1 || totals.Validation.DataSources["OTHER"].Good != 1 {
+		test.Errorf("expected the good and bad records by data source, got %+v", totals.Validation)
+	}
+}
+
+func TestRead_settle(test *testing.T) {
+	queue := &fakeQueue{records: []*input.Record{
+		{Body: `{"DATA_SOURCE": "TEST", "RECORD_ID": "1"}`, Source: "loaded", Retry: true},
+		{Body: `{"DATA_SOURCE": "TEST", "RECORD_ID": "BAD"}`, Source: "first failure", Retry: true},
+		{Body: `{"DATA_SOURCE": "TEST", "RECORD_ID": "BAD"}`, Source: "failed"},
+		{Body: `{"DATA_SOURCE": "TEST"}`, Source: "invalid"},
+		{Body: `{"DATA_SOURCE": "TEST", "RECORD_ID": "2"}`, Source: "deleted", Operation: "delete"},
+	}}
+	fakeQueues["settle"] = queue
+	ctx := context.TODO()
+	engine := &fakeEngine{}
+	err := input.Read(ctx, ctx, input.ReadOptions{Engine: engine, InputURLs: []string{"fake://settle"}, NumberOfWorkers: 2, ValidationMode: "lenient"})
+	if err != nil {
+		test.Fatal(err)
+	}
+	expected := map[string]string{
+		"loaded":        "ack",
+		"first failure": "requeue",
+		"failed":        "reject",
+		"invalid":       "reject",
+		"deleted":       "ack",
+	}
+	for source, how := range expected {
+		if queue.settled[source] != how {
+			test.Errorf("expected the %s record to be settled with %s, got %q", source, how, queue.settled[source])
+		}
+	}
+	if _, ok := engine.deleted.Load("TEST:2"); !ok {
+		test.Error("expected TEST:2 to be deleted by its operation header")
+	}
+}
+
+func TestRead_fanIn(test *testing.T) {
+	for _, name := range []string{"a", "b"} {
+		queue := &fakeQueue{}
+		for i := 0; i < 40; i++ {
+			queue.records = append(queue.records, &input.Record{
+				Body:   fmt.Sprintf(`{"DATA_SOURCE": "%s", "RECORD_ID": "%d"}`, name, i),
+				Source: fmt.Sprintf("%s %d", name, i),
+			})
+		}
+		fakeQueues[name] = queue
+	}
+	metrics.Reset()
+	ctx := context.TODO()
+	engine := &fakeEngine{}
+	err := input.Read(ctx, ctx, input.ReadOptions{Engine: engine, InputURLs: []string{"fake://a", "fake://b#weight=3"}, NumberOfWorkers: 1, ValidationMode: "lenient"})
+	if err != nil {
+		test.Fatal(err)
+	}
+	totals := metrics.Snapshot()
+	if totals.Inputs["fake://a"].Loaded != 40 || totals.Inputs["fake://b"].Loaded != 40 {
+		test.Errorf("expected 40 records loaded from each input, got %+v", totals.Inputs)
+	}
+	fromB := 0
+	for _, dataSource := range engine.order[:20] {
+		if dataSource == "b" {
+			fromB++
+		}
+	}
+	if fromB < 10 || fromB == 20 {
+		test.Errorf("expected about 15 of the first 20 records from the weighted input, got %d", fromB)
+	}
+}
+
+func TestRead_invalidURLs(test *testing.T) {
+	ctx := context.TODO()
+	for _, inputURLs := range [][]string{
+		{},
+		{"file:///data/a.jsonl", "file:///data/a.jsonl"},
+		{"file:///data/a.jsonl#weight=0"},
+		{"file:///data/a.jsonl#priority=1"},
+	} {
+		err := input.Read(ctx, ctx, input.ReadOptions{InputURLs: inputURLs, NumberOfWorkers: 1, ValidationMode: "lenient"})
+		if err == nil {
+			test.Errorf("expected an error for %v", inputURLs)
+		}
+	}
+}
+
+func TestRead_unsupportedScheme(test *testing.T) {
+	ctx := context.TODO()
+	err := input.Read(ctx, ctx, input.ReadOptions{InputURLs: []string{"ftp://example.com/records.jsonl"}, NumberOfWorkers: 1, ValidationMode: "lenient"})
+	if err == nil || !strings.Contains(err.Error(), "file, http, https, kafka, nats, sqs") {
+		test.Errorf("expected the supported schemes to be listed, got %v", err)
+	}
+}
+
+func TestNew(test *testing.T) {
+	testCases := map[string]string{
+		"file:///data/records.jsonl":                                "*file.fileInput",
+		"https://example.com/data/records.jsonl":                    "*file.fileInput",
+		"kafka://localhost:9092/records":                            "*kafka.topicInput",
+		"nats://localhost:4222/records":                             "*nats.streamInput",
+		"https://sqs.us-east-1.amazonaws.com/000000000000/my-queue": "*sqs.queueInput",
+		"sqs://lookup?queue-name=my-queue":                          "*sqs.queueInput",
+	}
+	for inputURL, expected := range testCases {
+		in, err := input.New(inputURL, input.Options{})
+		if err != nil {
+			test.Fatal(err)
+		}
+		if actual := fmt.Sprintf("%T", in); actual != expected {
+			test.Errorf("New(%s) = %s, expected %s", inputURL, actual, expected)
+		}
+	}
+}
@@ -0,0 +1,576 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/roncewind/load/health"
+	"github.com/roncewind/load/input"
+	"github.com/roncewind/load/loaderror"
+	"github.com/roncewind/load/logging"
+	"github.com/roncewind/load/operation"
+	"github.com/roncewind/load/redact"
+	"github.com/segmentio/kafka-go"
+)
+
+var log = logging.New("input/kafka", map[int]string{
+	2801: "Reading",
+	2802: "Finished reading",
+	2803: "Joined the consumer group",
+	2804: "Dry run: reading each partition from the consumer group's committed offset",
+	3801: "The topic has fewer partitions than there are workers; the other workers are idle",
+	3802: "Kafka reader error",
+	4801: "Unable to commit the message's offset",
+})
+
+const (
+	// the scheme records from this input are labelled with in metrics
+	scheme = "kafka"
+	// the consumer group joined when the URL does not name one
+	defaultGroupID = "senzing-load"
+	// messages fetched and waiting for their partition's turn, per worker
+	pendingPerWorker = 100
+)
+
+func init() {
+	input.Register(input.Scheme{Name: scheme, New: New})
+}
+
+// ----------------------------------------------------------------------------
+// Types
+// ----------------------------------------------------------------------------
+
+// an input that consumes records from a Kafka topic as a member of a consumer
+// group
+type topicInput struct {
+	brokers         []string
+	deadLetter      writer
+	deadLetterTopic string
+	dryRun          bool
+	groupID         string
+	partitions      *partitions
+	reader          reader
+	topic           string
+	urlString       string
+	workers         int
+}
+
+// the parts of kafka.Reader the input uses
+type reader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, messages ...kafka.Message) error
+	Close() error
+}
+
+// the parts of kafka.Writer the input uses
+type writer interface {
+	WriteMessages(ctx context.Context, messages ...kafka.Message) error
+	Close() error
+}
+
+// the messages of each of a topic's partitions, fetched by readers of their
+// own outside any consumer group, for a dry run
+type partitionReaders struct {
+	cancel  context.CancelFunc
+	ctx     context.Context
+	fetched chan fetched
+	readers []reader
+	start   sync.Once
+}
+
+// a message fetched by a partition's reader, or why none could be
+type fetched struct {
+	err     error
+	message kafka.Message
+}
+
+// a message being processed
+type handle struct {
+	message kafka.Message
+	retried bool
+}
+
+// the messages fetched from each partition, waiting their turn.  one message
+// per partition is processed at a time, so each partition's records are
+// processed, and their offsets committed, in order; unless they are not
+// ordered, for a dry run that never commits.
+type partitions struct {
+	busy    map[int]bool
+	count   int
+	lock    sync.Mutex
+	order   []int
+	ordered bool
+	pending map[int][]*handle
+	ready   chan struct{}
+}
+
+// ----------------------------------------------------------------------------
+
+// New returns an input for the records in a Kafka topic, eg.
+// kafka://broker1:9092/records?broker=broker2:9092&group-id=load; any other
+// brokers are given with broker, which may be repeated, as commas separate
+// input URLs.  the topic's partitions are shared with the other members of
+// the consumer group, and the records of each partition are processed one at
+// a time, by as many workers as there are partitions.  a message's offset is
+// committed once it has been processed or dead lettered, to the
+// dead-letter-topic, when the URL names one.  a dry run reads each partition
+// from the consumer group's committed offset without joining the group, so
+// the group's members are not rebalanced.
+func New(u *url.URL, options input.Options) (input.Input, error) {
+	topic := strings.Trim(u.Path, "/")
+	if u.Host == "" || topic == "" || strings.Contains(topic, "/") {
+		return nil, loaderror.ConfigurationError{Err: fmt.Errorf("the Kafka URL %s does not name the brokers and a topic, eg. kafka://localhost:9092/records", redact.URL(u.String()))}
+	}
+	query := u.Query()
+	groupID := query.Get("group-id")
+	if groupID == "" {
+		groupID = defaultGroupID
+	}
+	return &topicInput{
+		brokers:         append([]string{u.Host}, query["broker"]...),
+		deadLetterTopic: query.Get("dead-letter-topic"),
+		dryRun:          options.DryRun,
+		groupID:         groupID,
+		partitions:      newPartitions(!options.DryRun),
+		topic:           topic,
+		urlString:       u.String(),
+		workers:         options.NumberOfWorkers,
+	}, nil
+}
+
+// ----------------------------------------------------------------------------
+
+// Open checks the topic can be read and joins the consumer group, or, in a
+// dry run, reads each of its partitions from the group's committed offset.
+func (in *topicInput) Open(ctx context.Context) error {
+	log.Log(2801, "url", redact.URL(in.urlString))
+	partitionIDs, err := in.readPartitions(ctx)
+	if err != nil {
+		return err
+	}
+	topicPartitions := len(partitionIDs)
+	if in.dryRun {
+		in.reader, err = in.readCommitted(ctx, partitionIDs)
+		if err != nil {
+			return err
+		}
+		log.Log(2804, "topic", in.topic, "group", in.groupID, "partitions", topicPartitions)
+		return nil
+	}
+	in.reader = kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     in.brokers,
+		ErrorLogger: in.errorLogger(),
+		GroupID:     in.groupID,
+		StartOffset: kafka.FirstOffset,
+		Topic:       in.topic,
+	})
+	if in.deadLetterTopic != "" {
+		in.deadLetter = &kafka.Writer{
+			Addr:         kafka.TCP(in.brokers...),
+			RequiredAcks: kafka.RequireAll,
+			Topic:        in.deadLetterTopic,
+		}
+	}
+	log.Log(2803, "topic", in.topic, "group", in.groupID, "partitions", topicPartitions)
+	if topicPartitions < in.workers {
+		log.Log(3801, "topic", in.topic, "partitions", topicPartitions, "workers", in.workers)
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------------------
+
+// Stream the messages fetched from the topic until the read context is done,
+// each once its partition's previous message has been settled.
+func (in *topicInput) Stream(readCtx context.Context, records chan<- *input.Record) error {
+	fetched := make(chan kafka.Message)
+	fetchErr := make(chan error, 1)
+	go func() {
+		for {
+			message, err := in.reader.FetchMessage(readCtx)
+			if err != nil {
+				fetchErr <- err
+				return
+			}
+			select {
+			case fetched <- message:
+			case <-readCtx.Done():
+				return
+			}
+		}
+	}()
+
+	for {
+		// only offer a record, or fetch another message, when there is room
+		var out chan<- *input.Record
+		var record *input.Record
+		next := in.partitions.next()
+		if next != nil {
+			out = records
+			record = in.record(next)
+		}
+		var fetch <-chan kafka.Message
+		if in.partitions.size() < pendingPerWorker*in.workers {
+			fetch = fetched
+		}
+
+		select {
+		case <-readCtx.Done():
+			// the offsets of messages not processed are not committed, so they
+			// are fetched again
+			log.Log(2802, "url", redact.URL(in.urlString))
+			return nil
+		case err := <-fetchErr:
+			if readCtx.Err() != nil {
+				log.Log(2802, "url", redact.URL(in.urlString))
+				return nil
+			}
+			return loaderror.InputError{Err: fmt.Errorf("unable to fetch from the Kafka topic %s: %w", in.topic, err)}
+		case message := <-fetch:
+			in.partitions.add(&handle{message: message})
+		case out <- record:
+			in.partitions.take(next)
+		case <-in.partitions.ready:
+		}
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+// Ack commits the message's offset, and lets its partition's next message be
+// streamed.
+func (in *topicInput) Ack(ctx context.Context, record *input.Record) error {
+	messageHandle := record.Handle.(*handle)
+	defer in.partitions.done(messageHandle, false)
+	return in.commit(ctx, messageHandle)
+}
+
+// ----------------------------------------------------------------------------
+
+// Nack streams the message again while the input is reading; its offset is
+// not committed, so otherwise it is fetched again by the next consumer to
+// read the partition.  without requeue, the message is written to the dead
+// letter topic, when there is one, and its offset committed; without a dead
+// letter topic it is dropped, as RabbitMQ drops a rejected delivery without
+// a dead letter exchange.
+func (in *topicInput) Nack(ctx context.Context, record *input.Record, requeue bool) error {
+	messageHandle := record.Handle.(*handle)
+	if requeue {
+		messageHandle.retried = true
+		in.partitions.done(messageHandle, true)
+		return nil
+	}
+	if in.deadLetter != nil {
+		err := in.deadLetter.WriteMessages(ctx, deadLetterMessage(messageHandle.message))
+		if err != nil {
+			// the message is tried again rather than lost
+			messageHandle.retried = true
+			in.partitions.done(messageHandle, true)
+			return fmt.Errorf("unable to write to the Kafka dead letter topic %s: %w", in.deadLetterTopic, err)
+		}
+	}
+	defer in.partitions.done(messageHandle, false)
+	return in.commit(ctx, messageHandle)
+}
+
+// ----------------------------------------------------------------------------
+
+// Close leaves the consumer group, or closes a dry run's partition readers;
+// messages whose offsets were not committed are fetched again by the group's
+// other members.
+func (in *topicInput) Close() error {
+	var errs []error
+	if in.reader != nil {
+		errs = append(errs, in.reader.Close())
+	}
+	if in.deadLetter != nil {
+		errs = append(errs, in.deadLetter.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// ----------------------------------------------------------------------------
+
+// the IDs of the topic's partitions, as read from the first broker that can
+// be reached
+func (in *topicInput) readPartitions(ctx context.Context) ([]int, error) {
+	var errs []error
+	for _, broker := range in.brokers {
+		conn, err := kafka.DialContext(ctx, "tcp", broker)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		topicPartitions, err := conn.ReadPartitions(in.topic)
+		conn.Close()
+		if err != nil {
+			return nil, loaderror.InputError{Err: fmt.Errorf("unable to read the partitions of the Kafka topic %s: %w", in.topic, err)}
+		}
+		partitionIDs := make([]int, 0, len(topicPartitions))
+		for _, partition := range topicPartitions {
+			partitionIDs = append(partitionIDs, partition.ID)
+		}
+		return partitionIDs, nil
+	}
+	return nil, loaderror.ConnectionError{Err: fmt.Errorf("unable to reach a Kafka broker: %w", redact.Error(errors.Join(errs...)))}
+}
+
+// ----------------------------------------------------------------------------
+
+// a reader of each of the partitions, from the consumer group's committed
+// offset, or the first when the group has none, without joining the group
+func (in *topicInput) readCommitted(ctx context.Context, partitionIDs []int) (reader, error) {
+	client := &kafka.Client{Addr: kafka.TCP(in.brokers...)}
+	response, err := client.OffsetFetch(ctx, &kafka.OffsetFetchRequest{
+		GroupID: in.groupID,
+		Topics:  map[string][]int{in.topic: partitionIDs},
+	})
+	if err == nil {
+		err = response.Error
+	}
+	if err != nil {
+		return nil, loaderror.InputError{Err: fmt.Errorf("unable to fetch the offsets committed by the Kafka consumer group %s: %w", in.groupID, redact.Error(err))}
+	}
+	readers := newPartitionReaders(nil)
+	for _, committed := range response.Topics[in.topic] {
+		partitionReader := kafka.NewReader(kafka.ReaderConfig{
+			Brokers:     in.brokers,
+			ErrorLogger: in.errorLogger(),
+			Partition:   committed.Partition,
+			Topic:       in.topic,
+		})
+		readers.readers = append(readers.readers, partitionReader)
+		offset := committed.CommittedOffset
+		if offset < 0 {
+			offset = kafka.FirstOffset
+		}
+		err := committed.Error
+		if err == nil {
+			err = partitionReader.SetOffset(offset)
+		}
+		if err != nil {
+			readers.Close()
+			return nil, loaderror.InputError{Err: fmt.Errorf("unable to read partition %d of the Kafka topic %s from its committed offset: %w", committed.Partition, in.topic, err)}
+		}
+	}
+	return readers, nil
+}
+
+// ----------------------------------------------------------------------------
+
+// a logger of the Kafka readers' errors
+func (in *topicInput) errorLogger() kafka.Logger {
+	return kafka.LoggerFunc(func(message string, args ...interface{}) {
+		log.Log(3802, "topic", in.topic, "error", fmt.Sprintf(message, args...))
+	})
+}
+
+// ----------------------------------------------------------------------------
+
+// the record for a message
+func (in *topicInput) record(messageHandle *handle) *input.Record {
+	message := messageHandle.message
+	return &input.Record{
+		Body:      string(message.Value),
+		Handle:    messageHandle,
+		Operation: operationHeader(message),
+		// a first failure may be transient; let a consumer try again
+		Retry:  !messageHandle.retried,
+		Source: fmt.Sprintf("kafka topic %s partition %d offset %d", message.Topic, message.Partition, message.Offset),
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+// commit the message's offset
+func (in *topicInput) commit(ctx context.Context, messageHandle *handle) error {
+	err := in.reader.CommitMessages(ctx, messageHandle.message)
+	if err != nil {
+		log.Log(4801, "topic", in.topic, "partition", messageHandle.message.Partition, "offset", messageHandle.message.Offset, "error", err)
+		health.InputNotReady(fmt.Sprintf("unable to commit to Kafka: %v", err))
+		return err
+	}
+	health.InputReady()
+	return nil
+}
+
+// ----------------------------------------------------------------------------
+
+// the operation named by the message's operation header; "" when it has none
+func operationHeader(message kafka.Message) string {
+	for _, header := range message.Headers {
+		if header.Key == operation.Header {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+// ----------------------------------------------------------------------------
+
+// a copy of the message for the dead letter topic
+func deadLetterMessage(message kafka.Message) kafka.Message {
+	return kafka.Message{
+		Headers: message.Headers,
+		Key:     message.Key,
+		Value:   message.Value,
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+func newPartitions(ordered bool) *partitions {
+	return &partitions{
+		busy:    map[int]bool{},
+		ordered: ordered,
+		pending: map[int][]*handle{},
+		ready:   make(chan struct{}, 1),
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+// add a message fetched to the end of its partition's queue
+func (p *partitions) add(messageHandle *handle) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	partition := messageHandle.message.Partition
+	if _, ok := p.pending[partition]; !ok {
+		p.order = append(p.order, partition)
+	}
+	p.pending[partition] = append(p.pending[partition], messageHandle)
+	p.count++
+}
+
+// ----------------------------------------------------------------------------
+
+// the first message of the next partition in turn that has one waiting and
+// none being processed; nil when there is none
+func (p *partitions) next() *handle {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, partition := range p.order {
+		if queue := p.pending[partition]; len(queue) > 0 && !p.busy[partition] {
+			return queue[0]
+		}
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------------------
+
+// take the partition's first message to be processed, and give the next
+// partition the first turn
+func (p *partitions) take(messageHandle *handle) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	partition := messageHandle.message.Partition
+	p.pending[partition] = p.pending[partition][1:]
+	p.count--
+	p.busy[partition] = p.ordered
+	for i, turn := range p.order {
+		if turn == partition {
+			p.order = append(append(p.order[:i:i], p.order[i+1:]...), partition)
+			break
+		}
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+// the message has been settled, or is to be streamed again first when
+// requeued; either way its partition's next message can be streamed
+func (p *partitions) done(messageHandle *handle, requeue bool) {
+	p.lock.Lock()
+	partition := messageHandle.message.Partition
+	p.busy[partition] = false
+	if requeue {
+		p.pending[partition] = append([]*handle{messageHandle}, p.pending[partition]...)
+		p.count++
+	}
+	p.lock.Unlock()
+	select {
+	case p.ready <- struct{}{}:
+	default:
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+// the number of messages waiting their turn
+func (p *partitions) size() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.count
+}
+
+// ----------------------------------------------------------------------------
+
+func newPartitionReaders(readers []reader) *partitionReaders {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &partitionReaders{
+		cancel:  cancel,
+		ctx:     ctx,
+		fetched: make(chan fetched),
+		readers: readers,
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+// FetchMessage returns the next message fetched from any of the partitions.
+func (r *partitionReaders) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	r.start.Do(func() {
+		for _, partitionReader := range r.readers {
+			go r.fetch(partitionReader)
+		}
+	})
+	select {
+	case next := <-r.fetched:
+		return next.message, next.err
+	case <-ctx.Done():
+		return kafka.Message{}, ctx.Err()
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+// CommitMessages fails; offsets are only committed by a consumer group's
+// members.
+func (r *partitionReaders) CommitMessages(ctx context.Context, messages ...kafka.Message) error {
+	return errors.New("offsets are not committed in a dry run")
+}
+
+// ----------------------------------------------------------------------------
+
+// Close stops fetching and closes the partitions' readers.
+func (r *partitionReaders) Close() error {
+	r.cancel()
+	var errs []error
+	for _, partitionReader := range r.readers {
+		errs = append(errs, partitionReader.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// ----------------------------------------------------------------------------
+
+// fetch the partition's messages, until the first error or the readers are
+// closed
+func (r *partitionReaders) fetch(partitionReader reader) {
+	for {
+		message, err := partitionReader.FetchMessage(r.ctx)
+		select {
+		case r.fetched <- fetched{err: err, message: message}:
+		case <-r.ctx.Done():
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
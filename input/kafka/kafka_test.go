@@ -0,0 +1,238 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/roncewind/load/input"
+	"github.com/segmentio/kafka-go"
+)
+
+// ----------------------------------------------------------------------------
+// Test helpers
+// ----------------------------------------------------------------------------
+
+// a reader of the given messages, recording the offsets committed
+type fakeReader struct {
+	committed []kafka.Message
+	lock      sync.Mutex
+	messages  chan kafka.Message
+}
+
+func newFakeReader(messages ...kafka.Message) *fakeReader {
+	reader := &fakeReader{messages: make(chan kafka.Message, len(messages))}
+	for _, message := range messages {
+		reader.messages <- message
+	}
+	return reader
+}
+
+func (reader *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	select {
+	case message := <-reader.messages:
+		return message, nil
+	case <-ctx.Done():
+		return kafka.Message{}, ctx.Err()
+	}
+}
+
+func (reader *fakeReader) CommitMessages(ctx context.Context, messages ...kafka.Message) error {
+	reader.lock.Lock()
+	defer reader.lock.Unlock()
+	reader.committed = append(reader.committed, messages...)
+	return nil
+}
+
+func (reader *fakeReader) Close() error { return nil }
+
+// a writer recording the messages written, or failing
+type fakeWriter struct {
+	err     error
+	written []kafka.Message
+}
+
+func (writer *fakeWriter) WriteMessages(ctx context.Context, messages ...kafka.Message) error {
+	if writer.err != nil {
+		return writer.err
+	}
+	writer.written = append(writer.written, messages...)
+	return nil
+}
+
+func (writer *fakeWriter) Close() error { return nil }
+
+func message(partition int, offset int64) kafka.Message {
+	return kafka.Message{Topic: "records", Partition: partition, Offset: offset, Value: []byte(`{}`)}
+}
+
+// an input streaming the messages to a channel until the test is done
+func stream(test *testing.T, dryRun bool, messages ...kafka.Message) (*topicInput, *fakeReader, <-chan *input.Record) {
+	reader := newFakeReader(messages...)
+	in := &topicInput{
+		partitions: newPartitions(!dryRun),
+		reader:     reader,
+		topic:      "records",
+		workers:    1,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	records := make(chan *input.Record)
+	done := make(chan error)
+	go func() { done <- in.Stream(ctx, records) }()
+	test.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			test.Error(err)
+		}
+	})
+	return in, reader, records
+}
+
+// the next record streamed, or nil when there is none
+func receive(records <-chan *input.Record) *input.Record {
+	select {
+	case record := <-records:
+		return record
+	case <-time.After(100 * time.Millisecond):
+		return nil
+	}
+}
+
+func offsetOf(record *input.Record) int64 {
+	return record.Handle.(*handle).message.Offset
+}
+
+// ----------------------------------------------------------------------------
+// Test interface functions
+// ----------------------------------------------------------------------------
+
+func TestNew(test *testing.T) {
+	u, _ := url.Parse("kafka://broker1:9092/records?broker=broker2:9092&dead-letter-topic=failed")
+	in, err := New(u, input.Options{NumberOfWorkers: 4})
+	if err != nil {
+		test.Fatal(err)
+	}
+	topic := in.(*topicInput)
+	if len(topic.brokers) != 2 || topic.brokers[1] != "broker2:9092" || topic.topic != "records" || topic.groupID != defaultGroupID || topic.deadLetterTopic != "failed" {
+		test.Errorf("New(%s) = %+v", u, topic)
+	}
+
+	for _, urlString := range []string{"kafka:///records", "kafka://localhost:9092", "kafka://localhost:9092/records/more"} {
+		u, _ := url.Parse(urlString)
+		if _, err := New(u, input.Options{}); err == nil {
+			test.Errorf("New(%s) expected an error", urlString)
+		}
+	}
+}
+
+func TestStream_partitionOrder(test *testing.T) {
+	in, reader, records := stream(test, false, message(0, 1), message(0, 2), message(1, 1))
+
+	// one record from each partition at a time
+	first, second := receive(records), receive(records)
+	if first == nil || second == nil || receive(records) != nil {
+		test.Fatal("expected one record from each partition")
+	}
+	if first.Handle.(*handle).message.Partition == second.Handle.(*handle).message.Partition {
+		test.Fatal("expected records from different partitions")
+	}
+	partitionZero := first
+	if first.Handle.(*handle).message.Partition != 0 {
+		partitionZero = second
+	}
+	if err := in.Ack(context.Background(), partitionZero); err != nil {
+		test.Fatal(err)
+	}
+	next := receive(records)
+	if next == nil || offsetOf(next) != 2 {
+		test.Fatalf("expected partition 0 offset 2 once offset 1 was acknowledged, got %v", next)
+	}
+	if len(reader.committed) != 1 || reader.committed[0].Offset != 1 {
+		test.Errorf("committed %v, expected offset 1", reader.committed)
+	}
+}
+
+func TestNack(test *testing.T) {
+	in, reader, records := stream(test, false, message(0, 1), message(0, 2))
+
+	record := receive(records)
+	if !record.Retry {
+		test.Error("expected a first delivery to be retried")
+	}
+	in.Nack(context.Background(), record, true)
+	record = receive(records)
+	if record == nil || offsetOf(record) != 1 || record.Retry {
+		test.Fatalf("expected offset 1 again, without a retry, got %v", record)
+	}
+
+	// a dead letter that cannot be written is tried again
+	deadLetter := &fakeWriter{err: errors.New("unreachable")}
+	in.deadLetter = deadLetter
+	if err := in.Nack(context.Background(), record, false); err == nil {
+		test.Error("expected an error writing the dead letter")
+	}
+	record = receive(records)
+	if record == nil || offsetOf(record) != 1 || len(reader.committed) != 0 {
+		test.Fatalf("expected offset 1 again, uncommitted, got %v", record)
+	}
+
+	deadLetter.err = nil
+	if err := in.Nack(context.Background(), record, false); err != nil {
+		test.Fatal(err)
+	}
+	if len(deadLetter.written) != 1 || len(reader.committed) != 1 {
+		test.Errorf("expected the message dead lettered and committed, wrote %v committed %v", deadLetter.written, reader.committed)
+	}
+	if record = receive(records); record == nil || offsetOf(record) != 2 {
+		test.Errorf("expected offset 2, got %v", record)
+	}
+}
+
+func TestStream_dryRun(test *testing.T) {
+	_, _, records := stream(test, true, message(0, 1), message(0, 2))
+
+	// nothing is settled in a dry run, so records are not held back
+	for offset := int64(1); offset <= 2; offset++ {
+		if record := receive(records); record == nil || offsetOf(record) != offset {
+			test.Fatalf("expected offset %d, got %v", offset, record)
+		}
+	}
+}
+
+func TestPartitionReaders(test *testing.T) {
+	readers := newPartitionReaders([]reader{newFakeReader(message(0, 1)), newFakeReader(message(1, 5))})
+	defer readers.Close()
+
+	// the messages of every partition are fetched
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	fetched := map[int]int64{}
+	for i := 0; i < 2; i++ {
+		message, err := readers.FetchMessage(ctx)
+		if err != nil {
+			test.Fatal(err)
+		}
+		fetched[message.Partition] = message.Offset
+	}
+	if fetched[0] != 1 || fetched[1] != 5 {
+		test.Errorf("expected offset 1 of partition 0 and 5 of 1, got %v", fetched)
+	}
+
+	// and none are committed
+	if err := readers.CommitMessages(ctx, message(0, 1)); err == nil {
+		test.Error("expected committing to fail")
+	}
+}
+
+func TestOperationHeader(test *testing.T) {
+	message := kafka.Message{Headers: []kafka.Header{{Key: "operation", Value: []byte("delete")}}}
+	if actual := operationHeader(message); actual != "delete" {
+		test.Errorf("operationHeader() = %q, expected delete", actual)
+	}
+	if actual := operationHeader(kafka.Message{}); actual != "" {
+		test.Errorf("operationHeader() = %q, expected none", actual)
+	}
+}
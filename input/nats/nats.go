@@ -0,0 +1,410 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/roncewind/load/health"
+	"github.com/roncewind/load/input"
+	"github.com/roncewind/load/loaderror"
+	"github.com/roncewind/load/logging"
+	"github.com/roncewind/load/operation"
+	"github.com/roncewind/load/redact"
+)
+
+var log = logging.New("input/nats", map[int]string{
+	2901: "Reading",
+	2902: "Finished reading",
+	2903: "Bound to the JetStream consumer",
+	2904: "Reconnected to NATS",
+	3901: "The NATS connection was lost, reconnecting",
+	3902: "Unable to fetch from the JetStream consumer, trying again",
+	4901: "Unable to mark the message in progress",
+	4902: "Unable to return the message to the consumer",
+})
+
+const (
+	// the scheme records from this input are labelled with in metrics
+	scheme = "nats"
+	// the durable consumer bound to when the URL does not name one
+	defaultConsumer = "senzing-load"
+	// the deliveries of a message, when a new consumer is created, before it
+	// is dead lettered
+	defaultMaxDeliver = 5
+	// how long to wait before fetching again after a fetch fails
+	fetchRetryDelay = 2 * time.Second
+)
+
+func init() {
+	input.Register(input.Scheme{Name: scheme, New: New})
+}
+
+// ----------------------------------------------------------------------------
+// Types
+// ----------------------------------------------------------------------------
+
+// an input that fetches records from a JetStream consumer
+type streamInput struct {
+	ackWait           time.Duration
+	conn              *nats.Conn
+	consumer          string
+	ctx               context.Context
+	deadLetterSubject string
+	dryRun            bool
+	jetStream         nats.JetStreamContext
+	maxDeliver        int
+	servers           []string
+	stream            string
+	subject           string
+	subscription      *nats.Subscription
+	urlString         string
+	workers           int
+}
+
+// a message being processed, and how to stop marking it in progress
+type handle struct {
+	message        *nats.Msg
+	progressCancel context.CancelFunc
+}
+
+// ----------------------------------------------------------------------------
+
+// New returns an input for the records published to a JetStream subject, eg.
+// nats://localhost:4222/records?consumer=load&dead-letter-subject=failed.
+// records are fetched from the durable pull consumer, which is created on the
+// subject's stream when it does not exist.  a message is acknowledged once it
+// has been processed, and marked in progress until then, so it is only
+// delivered again once the visibility period has passed without word from a
+// stopped load.  a message that fails on its last delivery, as limited by
+// max-deliver, is dead lettered.  a dry run reads the messages the durable
+// consumer has yet to acknowledge, and those published while it runs, with a
+// consumer of its own, leaving the durable consumer as it was.  servers other than the URL's host are given with server, which may
+// be repeated, as commas separate input URLs.
+func New(u *url.URL, options input.Options) (input.Input, error) {
+	subject := strings.Trim(u.Path, "/")
+	if u.Host == "" || subject == "" {
+		return nil, loaderror.ConfigurationError{Err: fmt.Errorf("the NATS URL %s does not name the servers and a subject, eg. nats://localhost:4222/records", redact.URL(u.String()))}
+	}
+	query := u.Query()
+	consumer := query.Get("consumer")
+	if consumer == "" {
+		consumer = defaultConsumer
+	}
+	maxDeliver := 0
+	if query.Has("max-deliver") {
+		var err error
+		maxDeliver, err = strconv.Atoi(query.Get("max-deliver"))
+		if err != nil || maxDeliver == 0 || maxDeliver < -1 {
+			return nil, loaderror.ConfigurationError{Err: fmt.Errorf("the NATS URL's max-deliver must be a number of deliveries, or -1 for no limit: %s", redact.URL(u.String()))}
+		}
+	}
+
+	// the servers share the URL's credentials
+	var servers []string
+	for _, host := range append([]string{u.Host}, query["server"]...) {
+		server := url.URL{Scheme: u.Scheme, Host: host, User: u.User}
+		servers = append(servers, server.String())
+	}
+	return &streamInput{
+		ackWait:           time.Duration(options.VisibilityPeriodInSeconds) * time.Second,
+		consumer:          consumer,
+		deadLetterSubject: query.Get("dead-letter-subject"),
+		dryRun:            options.DryRun,
+		maxDeliver:        maxDeliver,
+		servers:           servers,
+		stream:            query.Get("stream"),
+		subject:           subject,
+		urlString:         u.String(),
+		workers:           options.NumberOfWorkers,
+	}, nil
+}
+
+// ----------------------------------------------------------------------------
+
+// Open connects to NATS and binds to the consumer, creating it or updating
+// its ack wait and max deliveries.  messages are marked in progress until ctx
+// is done.
+func (in *streamInput) Open(ctx context.Context) error {
+	log.Log(2901, "url", redact.URL(in.urlString))
+	conn, err := nats.Connect(strings.Join(in.servers, ","),
+		nats.Name("senzing-load"),
+		nats.DisconnectErrHandler(func(conn *nats.Conn, err error) {
+			health.InputNotReady("the NATS connection was lost, reconnecting")
+			log.Log(3901, "error", err)
+		}),
+		nats.ReconnectHandler(func(conn *nats.Conn) {
+			health.InputReady()
+			log.Log(2904, "server", redact.URL(conn.ConnectedUrl()))
+		}),
+	)
+	if err != nil {
+		return loaderror.ConnectionError{Err: fmt.Errorf("unable to connect to NATS: %w", redact.Error(err))}
+	}
+	in.conn = conn
+	in.ctx = ctx
+
+	in.jetStream, err = conn.JetStream()
+	if err == nil && in.stream == "" {
+		in.stream, err = in.jetStream.StreamNameBySubject(in.subject)
+	}
+	if err == nil {
+		err = in.subscribe()
+	}
+	if err != nil {
+		conn.Close()
+		return loaderror.InputError{Err: fmt.Errorf("unable to fetch %s from JetStream: %w", in.subject, err)}
+	}
+	log.Log(2903, "stream", in.stream, "consumer", in.consumer, "ackWait", in.ackWait.String(), "maxDeliver", in.maxDeliver)
+	return nil
+}
+
+// ----------------------------------------------------------------------------
+
+// Stream the messages fetched from the consumer until the read context is
+// done.
+func (in *streamInput) Stream(readCtx context.Context, records chan<- *input.Record) error {
+	for readCtx.Err() == nil {
+		messages, err := in.subscription.Fetch(in.workers, nats.Context(readCtx))
+		if err != nil {
+			switch {
+			case readCtx.Err() != nil:
+			case errors.Is(err, nats.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+				// nothing was published in time
+			case errors.Is(err, nats.ErrConnectionClosed), errors.Is(err, nats.ErrBadSubscription):
+				return loaderror.InputError{Err: fmt.Errorf("unable to fetch from the JetStream consumer %s: %w", in.consumer, err)}
+			default:
+				log.Log(3902, "consumer", in.consumer, "error", err)
+				select {
+				case <-readCtx.Done():
+				case <-time.After(fetchRetryDelay):
+				}
+			}
+			continue
+		}
+
+		handles := make([]*handle, len(messages))
+		for i, message := range messages {
+			handles[i] = in.handle(message)
+		}
+		for i, messageHandle := range handles {
+			select {
+			case records <- in.record(messageHandle):
+				continue
+			case <-readCtx.Done():
+			}
+			// the messages not streamed are delivered again straight away
+			for _, unstreamed := range handles[i:] {
+				in.requeue(unstreamed)
+			}
+			break
+		}
+	}
+	log.Log(2902, "url", redact.URL(in.urlString))
+	return nil
+}
+
+// ----------------------------------------------------------------------------
+
+// Ack acknowledges the message, so it is not delivered again.
+func (in *streamInput) Ack(ctx context.Context, record *input.Record) error {
+	messageHandle := record.Handle.(*handle)
+	messageHandle.progressCancel()
+	err := messageHandle.message.AckSync()
+	if err != nil {
+		health.InputNotReady(fmt.Sprintf("unable to reach NATS: %v", err))
+		return err
+	}
+	health.InputReady()
+	return nil
+}
+
+// ----------------------------------------------------------------------------
+
+// Nack has the message delivered again straight away or, without requeue,
+// publishes it to the dead letter subject, when there is one, and terminates
+// it so that it is never delivered again; NATS then publishes a terminated
+// advisory for it.  if the dead letter cannot be published, the message is
+// left to be delivered again once its ack wait has passed.
+func (in *streamInput) Nack(ctx context.Context, record *input.Record, requeue bool) error {
+	messageHandle := record.Handle.(*handle)
+	messageHandle.progressCancel()
+	if requeue {
+		return messageHandle.message.Nak()
+	}
+	if in.deadLetterSubject != "" {
+		deadLetter := &nats.Msg{
+			Data:    messageHandle.message.Data,
+			Header:  messageHandle.message.Header,
+			Subject: in.deadLetterSubject,
+		}
+		if _, err := in.jetStream.PublishMsg(deadLetter); err != nil {
+			return fmt.Errorf("unable to publish to the NATS dead letter subject %s: %w", in.deadLetterSubject, err)
+		}
+	}
+	return messageHandle.message.Term()
+}
+
+// ----------------------------------------------------------------------------
+
+// Close the connection; messages not acknowledged are delivered again once
+// their ack wait has passed.  a dry run's consumer is deleted.
+func (in *streamInput) Close() error {
+	if in.conn == nil {
+		return nil
+	}
+	var err error
+	if in.dryRun && in.subscription != nil {
+		err = in.subscription.Unsubscribe()
+	}
+	in.conn.Close()
+	return err
+}
+
+// ----------------------------------------------------------------------------
+
+// subscribe to the durable consumer, creating it, or updating its ack wait
+// and max deliveries to the input's; or, for a dry run, to a consumer of its
+// own that delivers each message once and is deleted when the input closes.
+// it starts after the durable consumer's ack floor, or with the whole of the
+// stream when there is no durable consumer yet.
+func (in *streamInput) subscribe() error {
+	if in.dryRun {
+		return in.subscribeDryRun()
+	}
+
+	config := &nats.ConsumerConfig{
+		AckPolicy:     nats.AckExplicitPolicy,
+		Durable:       in.consumer,
+		FilterSubject: in.subject,
+		MaxDeliver:    defaultMaxDeliver,
+	}
+	info, err := in.jetStream.ConsumerInfo(in.stream, in.consumer)
+	switch {
+	case err == nil:
+		config = &info.Config
+	case !errors.Is(err, nats.ErrConsumerNotFound):
+		return err
+	}
+	if in.ackWait > 0 {
+		config.AckWait = in.ackWait
+	}
+	if in.maxDeliver != 0 {
+		config.MaxDeliver = in.maxDeliver
+	}
+	if info == nil {
+		info, err = in.jetStream.AddConsumer(in.stream, config)
+	} else {
+		info, err = in.jetStream.UpdateConsumer(in.stream, config)
+	}
+	if err != nil {
+		return err
+	}
+	in.ackWait = info.Config.AckWait
+	in.maxDeliver = info.Config.MaxDeliver
+	in.subscription, err = in.jetStream.PullSubscribe(in.subject, in.consumer, nats.Bind(in.stream, in.consumer))
+	return err
+}
+
+// ----------------------------------------------------------------------------
+
+// subscribe a dry run to a consumer of its own, starting with the first
+// message the durable consumer has not acknowledged
+func (in *streamInput) subscribeDryRun() error {
+	deliver := nats.DeliverAll()
+	info, err := in.jetStream.ConsumerInfo(in.stream, in.consumer)
+	switch {
+	case err == nil:
+		deliver = nats.StartSequence(info.AckFloor.Stream + 1)
+	case !errors.Is(err, nats.ErrConsumerNotFound):
+		return err
+	}
+	in.subscription, err = in.jetStream.PullSubscribe(in.subject, "", nats.BindStream(in.stream), nats.AckExplicit(), deliver, nats.MaxDeliver(1))
+	return err
+}
+
+// ----------------------------------------------------------------------------
+
+// the handle for a message fetched, marking it in progress until it is
+// settled, except in a dry run
+func (in *streamInput) handle(message *nats.Msg) *handle {
+	messageHandle := &handle{message: message, progressCancel: func() {}}
+	if !in.dryRun {
+		var progressContext context.Context
+		progressContext, messageHandle.progressCancel = context.WithCancel(in.ctx)
+		go markInProgress(in.ctx, progressContext, message, in.ackWait)
+	}
+	return messageHandle
+}
+
+// ----------------------------------------------------------------------------
+
+// the record for a message
+func (in *streamInput) record(messageHandle *handle) *input.Record {
+	message := messageHandle.message
+	source := fmt.Sprintf("nats subject %s", message.Subject)
+	var delivered uint64
+	if metadata, err := message.Metadata(); err == nil {
+		source = fmt.Sprintf("nats stream %s sequence %d", metadata.Stream, metadata.Sequence.Stream)
+		delivered = metadata.NumDelivered
+	}
+	// a failure before the last delivery may be transient; let a consumer
+	// try again.  without a limit, try once more.
+	retry := delivered == 1
+	if in.maxDeliver > 0 {
+		retry = delivered < uint64(in.maxDeliver)
+	}
+	return &input.Record{
+		Body:      string(message.Data),
+		Handle:    messageHandle,
+		Operation: message.Header.Get(operation.Header),
+		Retry:     retry,
+		Source:    source,
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+// have a message fetched but not streamed delivered again straight away
+func (in *streamInput) requeue(messageHandle *handle) {
+	messageHandle.progressCancel()
+	if in.dryRun {
+		return
+	}
+	if err := messageHandle.message.Nak(); err != nil {
+		log.Log(4902, "subject", messageHandle.message.Subject, "error", err)
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+// mark the message in progress, resetting its ack wait, until the progress
+// context is cancelled.  if the consumer is shutting down, have the message
+// delivered again straight away.
+func markInProgress(ctx, progressContext context.Context, message *nats.Msg, ackWait time.Duration) {
+	period := ackWait - time.Second
+	if period <= 0 {
+		period = ackWait / 2
+	}
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			message.Nak()
+			return
+		case <-progressContext.Done():
+			return
+		case <-ticker.C:
+			if err := message.InProgress(); err != nil {
+				log.Log(4901, "subject", message.Subject, "error", err)
+				return
+			}
+		}
+	}
+}
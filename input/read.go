@@ -0,0 +1,498 @@
+package input
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/roncewind/load/engine"
+	"github.com/roncewind/load/health"
+	"github.com/roncewind/load/limit"
+	"github.com/roncewind/load/loaderror"
+	"github.com/roncewind/load/logging"
+	"github.com/roncewind/load/metrics"
+	"github.com/roncewind/load/operation"
+	"github.com/roncewind/load/output"
+	"github.com/roncewind/load/redact"
+	"github.com/roncewind/load/validate"
+	"github.com/senzing/go-common/record"
+	"github.com/sourcegraph/conc/pool"
+)
+
+var log = logging.New("input", map[int]string{
+	1201: "Parsed the input URL",
+	2201: "Dry run: records are read and validated only; nothing is loaded, acknowledged or written",
+	2202: "Validation summary",
+	2204: "Jobs added to the job queue",
+	4201: "Invalid record",
+	4202: "Unable to process the record",
+	4203: "Unable to dead letter the record",
+	4204: "Unable to settle the record",
+	4205: "Unable to close the input",
+})
+
+// ----------------------------------------------------------------------------
+func parseURL(urlString string) (*url.URL, error) {
+	u, err := url.Parse(urlString)
+	if err != nil {
+		return nil, redact.Error(err)
+	}
+
+	if log.Enabled(1201) {
+		host, port, _ := net.SplitHostPort(u.Host)
+		user := u.User.Username()
+		if _, ok := u.User.Password(); ok {
+			user = url.UserPassword(user, redact.Mask).String()
+		}
+		query := map[string]interface{}{}
+		for key, values := range u.Query() {
+			query[key] = redact.Value(key, values)
+		}
+		log.Log(1201,
+			"scheme", u.Scheme,
+			"user", user,
+			"host", host,
+			"port", port,
+			"path", u.Path,
+			"fragment", u.Fragment,
+			"query", query,
+		)
+	}
+	return u, nil
+}
+
+// ----------------------------------------------------------------------------
+
+// Read records from the options' input URLs and load them with its engine,
+// returning a loaderror describing why the load stopped, if it did not
+// complete.  the records of every input are processed by the same workers,
+// taken from the inputs with records ready in turn; an input with a weight
+// fragment, eg. amqp://host/#weight=3, is given that many turns to the others'
+// one.  no more records are read once the read context is done, the limiter's
+// maximum number of records has been taken or an input fails; records in
+// flight are finished with the context.  records that cannot be loaded are
+// written to the dead letter writer, when one is given.  with a nil engine, a
+// dry run, records are only read and validated.
+func Read(ctx, readCtx context.Context, options ReadOptions) error {
+	if len(options.InputURLs) == 0 {
+		return loaderror.ConfigurationError{Err: errors.New("an input URL is required")}
+	}
+
+	validator, err := validate.NewValidator(options.ValidationMode)
+	if err != nil {
+		return loaderror.ConfigurationError{Err: err}
+	}
+	dryRun := options.Engine == nil
+
+	//default to the max number of OS threads
+	numberOfWorkers := options.NumberOfWorkers
+	if numberOfWorkers <= 0 {
+		numberOfWorkers = runtime.GOMAXPROCS(0)
+	}
+	inputOptions := Options{
+		CSVMapping:                options.CSVMapping,
+		DryRun:                    dryRun,
+		FileType:                  options.FileType,
+		NumberOfWorkers:           numberOfWorkers,
+		VisibilityPeriodInSeconds: options.VisibilityPeriodInSeconds,
+	}
+	sources := make([]*source, 0, len(options.InputURLs))
+	names := map[string]bool{}
+	for _, inputURL := range options.InputURLs {
+		s, err := newSource(inputURL, inputOptions)
+		if err != nil {
+			return err
+		}
+		if names[s.name] {
+			return loaderror.ConfigurationError{Err: fmt.Errorf("the input URL %s is given more than once", s.name)}
+		}
+		names[s.name] = true
+		sources = append(sources, s)
+	}
+
+	if dryRun {
+		log.Log(2201)
+	}
+	defer func() {
+		metrics.Validated(validator.Result())
+		log.Log(2202, "summary", validator.Summary(), "rules", validator.Counts())
+	}()
+
+	defer func() {
+		for _, s := range sources {
+			if !s.opened {
+				continue
+			}
+			if closeErr := s.input.Close(); closeErr != nil {
+				log.Log(4205, "url", s.name, "error", closeErr)
+			}
+		}
+	}()
+	for _, s := range sources {
+		err = s.input.Open(ctx)
+		if err != nil {
+			return err
+		}
+		s.opened = true
+	}
+	health.InputReady()
+
+	w := worker{
+		deadLetterWriter: options.DeadLetterWriter,
+		g2engine:         options.Engine,
+		validator:        validator,
+	}
+	return w.run(ctx, readCtx, sources, numberOfWorkers, options.Limiter)
+}
+
+// ----------------------------------------------------------------------------
+// Types
+// ----------------------------------------------------------------------------
+
+// ReadOptions are what Read reads, and how it loads the records.
+type ReadOptions struct {
+	// CSVMapping maps the columns of delimited files to attributes
+	CSVMapping CSVMapping
+	// DeadLetterWriter records that cannot be loaded are written to; they
+	// are dropped when nil
+	DeadLetterWriter output.Writer
+	// Engine the records are loaded with; a dry run when nil
+	Engine engine.Engine
+	// FileType of files; detected when empty
+	FileType string
+	// InputURLs records are read from
+	InputURLs []string
+	// Limiter of the records taken; no limit when nil
+	Limiter *limit.Limiter
+	// NumberOfWorkers processing the records; the number of OS threads when
+	// not positive
+	NumberOfWorkers int
+	// ValidationMode records are validated in
+	ValidationMode string
+	// VisibilityPeriodInSeconds a queue message is hidden from other
+	// consumers while it is processed
+	VisibilityPeriodInSeconds int
+}
+
+// what the workers need to process the records from the inputs
+type worker struct {
+	deadLetterWriter output.Writer
+	// stops the run with an error; the first is returned by run
+	fail      func(err error)
+	g2engine  engine.Engine
+	validator *validate.Validator
+}
+
+// an input records are read from, and how they are streamed
+type source struct {
+	done      bool
+	input     Input
+	jobCount  int
+	name      string
+	opened    bool
+	records   chan *Record
+	scheme    string
+	streamErr error
+	weight    int
+}
+
+// ----------------------------------------------------------------------------
+
+// the source for the input URL; the input registered for its scheme and its
+// weight, from the URL's fragment
+func newSource(inputURL string, options Options) (*source, error) {
+	u, err := parseURL(inputURL)
+	if err != nil {
+		return nil, loaderror.ConfigurationError{Err: fmt.Errorf("unable to parse the input URL: %w", err)}
+	}
+	weight := 1
+	if len(u.Fragment) > 0 {
+		fragment, err := url.ParseQuery(u.Fragment)
+		if err == nil {
+			weight, err = strconv.Atoi(fragment.Get("weight"))
+		}
+		if err != nil || weight < 1 || len(fragment) != 1 {
+			return nil, loaderror.ConfigurationError{Err: fmt.Errorf("the input URL %s has an invalid fragment %q; expected weight=<a whole number of at least 1>", redact.URL(inputURL), u.Fragment)}
+		}
+		u.Fragment = ""
+	}
+	scheme, err := lookup(u)
+	if err != nil {
+		return nil, err
+	}
+	in, err := scheme.New(u, options)
+	if err != nil {
+		return nil, err
+	}
+	return &source{
+		input:  in,
+		name:   redact.Label(u.String()),
+		scheme: scheme.label(),
+		weight: weight,
+	}, nil
+}
+
+// ----------------------------------------------------------------------------
+
+// stream the records from the sources to a pool of workers until they are
+// exhausted or the read context is done, and wait for the records taken to
+// be processed.  the first error that stopped a worker or a source
+// streaming, if any, is returned; it stops the others too.
+func (w worker) run(ctx, readCtx context.Context, sources []*source, numberOfWorkers int, limiter *limit.Limiter) error {
+	readCtx, stopReading := context.WithCancel(readCtx)
+	defer stopReading()
+	var failed error
+	var failOnce sync.Once
+	w.fail = func(err error) {
+		failOnce.Do(func() {
+			failed = err
+			stopReading()
+		})
+	}
+	for _, s := range sources {
+		s := s
+		// a record is kept ready, so a source does not lose its turn while it
+		// reads the next
+		s.records = make(chan *Record, 1)
+		go func() {
+			defer close(s.records)
+			s.streamErr = s.input.Stream(readCtx, s.records)
+			if s.streamErr != nil {
+				stopReading()
+			}
+		}()
+		metrics.Workers(s.scheme, numberOfWorkers)
+	}
+
+	p := pool.New().WithMaxGoroutines(numberOfWorkers)
+	turns := weightedTurns(sources)
+	turn := 0
+	for {
+		fetchStart := time.Now()
+		s, record := receive(sources, turns, &turn)
+		if s == nil {
+			break
+		}
+		metrics.Fetch(s.scheme, time.Since(fetchStart))
+		if !limiter.Take() {
+			// the maximum number of records has been read; queued records are
+			// returned to be read again
+			if !w.dryRun() {
+				s.input.Nack(ctx, record, true)
+			}
+			continue
+		}
+		p.Go(func() {
+			defer limiter.Done()
+			defer metrics.WorkerBusy(s.scheme)()
+			defer health.WorkerBusy()()
+			w.process(ctx, s, record)
+		})
+
+		s.jobCount++
+		if s.jobCount%10000 == 0 {
+			log.Log(2204, "url", s.name, "jobs", s.jobCount)
+		}
+	}
+
+	// Wait for all the records taken to be processed
+	p.Wait()
+	if failed != nil {
+		return failed
+	}
+	for _, s := range sources {
+		if s.streamErr != nil {
+			return s.streamErr
+		}
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------------------
+
+// the order the sources take turns in; each appears as many times as its
+// weight, spread out, eg. a, b, a, a for weights of 3 and 1
+func weightedTurns(sources []*source) []int {
+	var turns []int
+	for round := 0; len(turns) < totalWeight(sources); round++ {
+		for i, s := range sources {
+			if s.weight > round {
+				turns = append(turns, i)
+			}
+		}
+	}
+	return turns
+}
+
+func totalWeight(sources []*source) int {
+	total := 0
+	for _, s := range sources {
+		total += s.weight
+	}
+	return total
+}
+
+// ----------------------------------------------------------------------------
+
+// the next record and its source; from the source whose turn it is, or the
+// next in turn with a record ready, or else the first to have one.  a nil
+// source is returned once every source is done.
+func receive(sources []*source, turns []int, turn *int) (*source, *Record) {
+	for {
+		for i := range turns {
+			s := sources[turns[(*turn+i)%len(turns)]]
+			if s.done {
+				continue
+			}
+			select {
+			case record, ok := <-s.records:
+				if !ok {
+					s.done = true
+					continue
+				}
+				*turn = (*turn + i + 1) % len(turns)
+				return s, record
+			default:
+			}
+		}
+
+		// no record is ready; wait for the first
+		var cases []reflect.SelectCase
+		var waiting []*source
+		for _, s := range sources {
+			if !s.done {
+				cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(s.records)})
+				waiting = append(waiting, s)
+			}
+		}
+		if len(cases) == 0 {
+			return nil, nil
+		}
+		chosen, value, ok := reflect.Select(cases)
+		if !ok {
+			waiting[chosen].done = true
+			continue
+		}
+		return waiting[chosen], value.Interface().(*Record)
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+// add a single record to Senzing, or delete or reevaluate it as its operation
+// says, and settle it with the input.
+//   - processed records are acknowledged.
+//   - records that cannot be read, fail validation or are otherwise invalid
+//     are dead lettered.
+//   - records the engine rejects are requeued when the input asks for a
+//     retry, and dead lettered otherwise.
+//   - records interrupted by shutdown are requeued, when the input can.
+//
+// in a dry run records are only counted and never settled.
+func (w worker) process(ctx context.Context, s *source, inputRecord *Record) {
+	if inputRecord.Err != nil {
+		w.reject(ctx, s, inputRecord, inputRecord.Err)
+		return
+	}
+	recordOperation, recordJSON, operationErr := operation.Of(inputRecord.Operation, inputRecord.Body)
+	if operationErr != nil {
+		w.reject(ctx, s, inputRecord, operationErr)
+		return
+	}
+	if validateErr := w.validator.Validate(recordJSON); validateErr != nil {
+		w.reject(ctx, s, inputRecord, validateErr)
+		return
+	}
+	record, newRecordErr := record.NewRecord(recordJSON)
+	if newRecordErr != nil {
+		w.reject(ctx, s, inputRecord, newRecordErr)
+		return
+	}
+	metrics.RecordRead(s.scheme, s.name, record.DataSource)
+	if w.dryRun() {
+		// the record would have been processed
+		metrics.RecordSkipped(s.scheme, s.name, record.DataSource)
+		return
+	}
+
+	engineCallDone := metrics.EngineCall(s.scheme, record.DataSource)
+	engineErr := operation.Apply(ctx, w.g2engine, recordOperation, record)
+	engineCallDone()
+	if engineErr != nil {
+		log.Log(4202, "source", inputRecord.Source, logging.Record(record.DataSource, record.Id), "operation", recordOperation, "retry", inputRecord.Retry, "error", engineErr)
+		if ctx.Err() != nil || inputRecord.Retry {
+			// stopped before the record could be processed, or a failure
+			// that may be transient; let it be tried again
+			if s.input.Nack(ctx, inputRecord, true) == nil {
+				metrics.RecordRetried(s.scheme, s.name, record.DataSource)
+				return
+			}
+		}
+		metrics.RecordOperation(s.scheme, recordOperation, engineErr)
+		metrics.RecordFailed(s.scheme, s.name, record.DataSource, engineErr)
+		w.deadLetter(ctx, s, inputRecord, engineErr)
+		return
+	}
+
+	metrics.RecordOperation(s.scheme, recordOperation, nil)
+	metrics.RecordLoaded(s.scheme, s.name, record.DataSource)
+	w.ack(ctx, s, inputRecord)
+}
+
+// ----------------------------------------------------------------------------
+
+// count a record that failed before it got to the engine and dead letter it
+func (w worker) reject(ctx context.Context, s *source, inputRecord *Record, err error) {
+	metrics.RecordRejected(s.scheme, s.name, inputRecord.Body, err)
+	log.Log(4201, "source", inputRecord.Source, "error", err)
+	if !w.dryRun() {
+		w.deadLetter(ctx, s, inputRecord, err)
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+// hand a failed record to the dead letter writer and acknowledge it.  if it
+// cannot be written, the record is requeued, when the input can, so it is
+// not lost; when it cannot, the run fails.  without a dead letter writer,
+// the record is handed to the input's own dead lettering.
+func (w worker) deadLetter(ctx context.Context, s *source, inputRecord *Record, loadErr error) {
+	if w.deadLetterWriter == nil {
+		if err := s.input.Nack(ctx, inputRecord, false); err != nil {
+			log.Log(4203, "source", inputRecord.Source, "error", err)
+		}
+		return
+	}
+	err := output.WriteDeadLetter(ctx, w.deadLetterWriter, inputRecord.Body, inputRecord.Source, loadErr)
+	if err != nil {
+		log.Log(4203, "source", inputRecord.Source, "error", err)
+		if s.input.Nack(ctx, inputRecord, true) != nil {
+			w.fail(loaderror.ConnectionError{Err: fmt.Errorf("unable to write to the dead letter URL, so %s is lost: %w", inputRecord.Source, redact.Error(err))})
+		}
+		return
+	}
+	w.ack(ctx, s, inputRecord)
+}
+
+// ----------------------------------------------------------------------------
+
+// acknowledge a record that is done with
+func (w worker) ack(ctx context.Context, s *source, inputRecord *Record) {
+	if err := s.input.Ack(ctx, inputRecord); err != nil {
+		log.Log(4204, "source", inputRecord.Source, "error", err)
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+// whether records are only read and validated
+func (w worker) dryRun() bool {
+	return w.g2engine == nil
+}
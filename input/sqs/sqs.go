@@ -2,74 +2,381 @@ package sqs
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
 
-	"github.com/docktermj/go-xyzzy-helpers/logger"
-	"github.com/roncewind/move/io/rabbitmq/managedconsumer"
-	"github.com/roncewind/move/io/sqs"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/roncewind/load/health"
+	"github.com/roncewind/load/input"
+	"github.com/roncewind/load/loaderror"
+	"github.com/roncewind/load/logging"
+	"github.com/roncewind/load/operation"
+	"github.com/roncewind/load/redact"
+)
+
+var log = logging.New("input/sqs", map[int]string{
+	2501: "Reading",
+	2502: "Finished reading",
+	2503: "Started consuming",
+	3501: "Unable to receive messages from the queue; trying again",
+	3502: "Unable to find the queue's dead letter queue",
+	4503: "Unable to remove the message from the queue",
+	4504: "Unable to extend the message's visibility",
+})
 
-	"github.com/senzing/g2-sdk-go/g2api"
-	"github.com/senzing/go-sdk-abstract-factory/factory"
+const (
+	// the scheme records from this input are labelled with in metrics
+	scheme = "sqs"
+	// messages received at a time, the most SQS allows
+	receiveCount = 10
+	// how long a receive waits for messages to arrive, the longest SQS allows
+	receiveWaitSeconds = 20
+	// the wait before receiving again after failing to
+	receiveDelay = 2 * time.Second
+	// the longest SQS keeps a message invisible
+	maxVisibilitySeconds = 12 * 60 * 60
 )
 
-// load is 6201:  https://github.com/Senzing/knowledge-base/blob/main/lists/senzing-product-ids.md
-const MessageIdFormat = "senzing-6201%04d"
+// SQS queue URLs have the form https://<host>/<12 digit account id>/<queue name>
+var queuePath = regexp.MustCompile(`^/[0-9]{12}/[A-Za-z0-9_-]{1,80}(\.fifo)?/?$`)
+
+func init() {
+	//allows for using a dummy URL with just a queue-name
+	// eg  sqs://lookup?queue-name=myqueue
+	input.Register(input.Scheme{Name: scheme, New: New})
+	//uses actual AWS SQS URL, other http(s) URLs are files.
+	for _, name := range []string{"http", "https"} {
+		input.Register(input.Scheme{Name: name, Label: scheme, Match: IsSQSURL, New: New})
+	}
+}
 
+// ----------------------------------------------------------------------------
+// Types
 // ----------------------------------------------------------------------------
 
-// read and process records from the given queue until a system interrupt
-func Read(ctx context.Context, urlString, engineConfigJson string, engineLogLevel, numberOfWorkers, visibilityPeriodInSeconds int) {
+// an input that receives records from an SQS queue
+type queueInput struct {
+	client             *sqs.Client
+	ctx                context.Context
+	deadLetterQueueURL string
+	dryRun             bool
+	queueURL           string
+	urlString          string
+	visibilitySeconds  int32
+}
+
+// a message being processed, and how to stop extending its visibility
+type handle struct {
+	message          types.Message
+	visibilityCancel context.CancelFunc
+}
 
-	// Work with G2engine.
-	g2engine := createG2Engine(ctx, engineConfigJson, engineLogLevel)
-	defer g2engine.Destroy(ctx)
-	// var g2engine *g2api.G2engine = nil
+// ----------------------------------------------------------------------------
 
-	// fmt.Println(" [*] Waiting for messages. To exit press CTRL+C")
-	fmt.Println("reading:", urlString)
-	startErr := sqs.StartConsumer(ctx, urlString, numberOfWorkers, g2engine, false, int32(visibilityPeriodInSeconds))
-	if startErr != nil {
-		msg := "there was an unexpected issue; please report this as a bug."
-		if _, ok := startErr.(managedconsumer.ManagedConsumerError); ok {
-			msg = startErr.Error()
+// IsSQSURL reports whether the given http(s) URL is an SQS queue endpoint,
+// as opposed to a file that should be downloaded.  AWS queue hosts
+// (sqs.<region>.amazonaws.com, <region>.queue.amazonaws.com) are detected by
+// name, other hosts (eg. localstack) by the account id and queue name path.
+func IsSQSURL(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	if strings.HasPrefix(host, "sqs.") || strings.HasPrefix(host, "queue.") || strings.Contains(host, ".queue.") {
+		if strings.Contains(host, ".amazonaws.com") {
+			return true
 		}
-		handleError(1, startErr, msg)
 	}
-	fmt.Println("So long and thanks for all the fish.")
+	return queuePath.MatchString(u.Path)
 }
 
 // ----------------------------------------------------------------------------
 
-// create a G2Engine object, on error this function panics.
-// see failOnError
-func createG2Engine(ctx context.Context, engineConfigJson string, engineLogLevel int) g2api.G2engine {
-	senzingFactory := &factory.SdkAbstractFactoryImpl{}
-	g2Config, err := senzingFactory.GetG2config(ctx)
+// New returns an input for the records in an SQS queue, named by an SQS queue
+// URL or sqs://lookup?queue-name=<name>.  each message is kept invisible to
+// other consumers while it is processed.  a dry run leaves every message on
+// the queue.
+func New(u *url.URL, options input.Options) (input.Input, error) {
+	return &queueInput{
+		dryRun:            options.DryRun,
+		urlString:         u.String(),
+		visibilitySeconds: int32(options.VisibilityPeriodInSeconds),
+	}, nil
+}
+
+// ----------------------------------------------------------------------------
+
+// Open connects to SQS and looks up the queue, and the dead letter queue of
+// its redrive policy.  the visibility of the messages being processed is
+// extended until ctx is done.
+func (in *queueInput) Open(ctx context.Context) error {
+	log.Log(2501, "url", redact.URL(in.urlString))
+	awsConfig, err := config.LoadDefaultConfig(ctx)
 	if err != nil {
-		handleError(2, err, "Unable to retrieve the config")
+		return loaderror.ConfigurationError{Err: fmt.Errorf("unable to load the AWS configuration: %w", err)}
 	}
-	g2engine, err := senzingFactory.GetG2engine(ctx)
-
+	in.client = sqs.NewFromConfig(awsConfig)
+	in.queueURL, err = queueURL(ctx, in.client, in.urlString)
 	if err != nil {
-		logger.LogMessage(MessageIdFormat, 2000, err.Error())
-		handleError(3, err, "Unable to reach G2")
+		return loaderror.ConnectionError{Err: fmt.Errorf("unable to find the SQS queue %s: %w", redact.URL(in.urlString), redact.Error(err))}
 	}
-	if g2Config.GetSdkId(ctx) == "base" {
-		err = g2engine.Init(ctx, "load", engineConfigJson, engineLogLevel)
+	in.deadLetterQueueURL, err = in.redriveQueueURL(ctx)
+	if err != nil {
+		log.Log(3502, "queue", in.queueURL, "error", err)
+	}
+	in.ctx = ctx
+	return nil
+}
+
+// ----------------------------------------------------------------------------
+
+// Stream the messages received from the queue until the read context is done.
+// receiving is tried again, after a delay, when it fails.
+func (in *queueInput) Stream(readCtx context.Context, records chan<- *input.Record) error {
+	log.Log(2503, "queue", in.queueURL)
+	defer log.Log(2502, "url", redact.URL(in.urlString))
+
+	// a dry run leaves messages on the queue, so they are received again once
+	// their visibility expires; they are only streamed the first time.
+	seen := map[string]bool{}
+
+	for readCtx.Err() == nil {
+		output, err := in.client.ReceiveMessage(readCtx, &sqs.ReceiveMessageInput{
+			MaxNumberOfMessages:   receiveCount,
+			MessageAttributeNames: []string{"All"},
+			QueueUrl:              aws.String(in.queueURL),
+			VisibilityTimeout:     in.visibilitySeconds,
+			WaitTimeSeconds:       receiveWaitSeconds,
+		})
 		if err != nil {
-			handleError(4, err, "Could not Init G2")
+			if readCtx.Err() != nil {
+				break
+			}
+			health.InputNotReady(fmt.Sprintf("unable to reach SQS: %v", err))
+			log.Log(3501, "queue", in.queueURL, "error", err)
+			select {
+			case <-time.After(receiveDelay):
+			case <-readCtx.Done():
+			}
+			continue
+		}
+		health.InputReady()
+		in.stream(readCtx, output.Messages, records, seen)
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------------------
+
+// stream the messages received, skipping those a dry run has already seen,
+// until the read context is done
+func (in *queueInput) stream(readCtx context.Context, messages []types.Message, records chan<- *input.Record, seen map[string]bool) {
+	for _, message := range messages {
+		if in.dryRun {
+			if seen[*message.MessageId] {
+				continue
+			}
+			seen[*message.MessageId] = true
+		}
+		messageHandle := &handle{message: message, visibilityCancel: func() {}}
+		if !in.dryRun {
+			var visibilityContext context.Context
+			visibilityContext, messageHandle.visibilityCancel = context.WithCancel(in.ctx)
+			go in.extendVisibility(visibilityContext, message)
+		}
+		record := &input.Record{
+			Body:      aws.ToString(message.Body),
+			Handle:    messageHandle,
+			Operation: operationAttribute(message),
+			Source:    fmt.Sprintf("sqs queue %s message %s", in.queueURL, aws.ToString(message.MessageId)),
+		}
+		select {
+		case records <- record:
+		case <-readCtx.Done():
+			// the messages are received again once their visibility expires
+			messageHandle.visibilityCancel()
+			return
+		}
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+// Ack deletes the message from the queue.
+func (in *queueInput) Ack(ctx context.Context, record *input.Record) error {
+	messageHandle := record.Handle.(*handle)
+	messageHandle.visibilityCancel()
+	err := in.removeMessage(ctx, messageHandle.message)
+	if err != nil {
+		health.InputNotReady(fmt.Sprintf("unable to reach SQS: %v", err))
+		return err
+	}
+	health.InputReady()
+	return nil
+}
+
+// ----------------------------------------------------------------------------
+
+// Nack makes the message visible again straight away or, without requeue,
+// pushes it to the queue's redrive policy dead letter queue and deletes it.
+// if neither works, the message is left on the queue so that it reappears
+// once its visibility expires.
+func (in *queueInput) Nack(ctx context.Context, record *input.Record, requeue bool) error {
+	messageHandle := record.Handle.(*handle)
+	messageHandle.visibilityCancel()
+	if requeue {
+		return in.setVisibility(context.Background(), messageHandle.message, 0)
+	}
+	if in.deadLetterQueueURL == "" {
+		return errors.New("the SQS queue has no dead letter queue")
+	}
+	_, err := in.client.SendMessage(ctx, &sqs.SendMessageInput{
+		MessageAttributes: messageHandle.message.MessageAttributes,
+		MessageBody:       messageHandle.message.Body,
+		QueueUrl:          aws.String(in.deadLetterQueueURL),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to send to the SQS dead letter queue %s: %w", in.deadLetterQueueURL, err)
+	}
+	err = in.removeMessage(ctx, messageHandle.message)
+	if err != nil {
+		log.Log(4503, "messageId", aws.ToString(messageHandle.message.MessageId), "error", err)
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------------------
+
+// Close does nothing; the SQS client holds no connection open.
+func (in *queueInput) Close() error {
+	return nil
+}
+
+// ----------------------------------------------------------------------------
+
+// the URL of the dead letter queue named by the queue's redrive policy; ""
+// when it has none
+func (in *queueInput) redriveQueueURL(ctx context.Context) (string, error) {
+	attributes, err := in.client.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameRedrivePolicy},
+		QueueUrl:       aws.String(in.queueURL),
+	})
+	if err != nil {
+		return "", err
+	}
+	policy, ok := attributes.Attributes[string(types.QueueAttributeNameRedrivePolicy)]
+	if !ok {
+		return "", nil
+	}
+	var redrivePolicy struct {
+		DeadLetterTargetArn string `json:"deadLetterTargetArn"`
+	}
+	if err := json.Unmarshal([]byte(policy), &redrivePolicy); err != nil {
+		return "", fmt.Errorf("unable to parse the redrive policy: %w", err)
+	}
+	// arn:aws:sqs:<region>:<account id>:<queue name>
+	arn := strings.Split(redrivePolicy.DeadLetterTargetArn, ":")
+	if len(arn) != 6 {
+		return "", fmt.Errorf("unable to parse the dead letter queue ARN %q", redrivePolicy.DeadLetterTargetArn)
+	}
+	output, err := in.client.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{
+		QueueName:              aws.String(arn[5]),
+		QueueOwnerAWSAccountId: aws.String(arn[4]),
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(output.QueueUrl), nil
+}
+
+// ----------------------------------------------------------------------------
+
+// delete the message from the queue
+func (in *queueInput) removeMessage(ctx context.Context, message types.Message) error {
+	_, err := in.client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(in.queueURL),
+		ReceiptHandle: message.ReceiptHandle,
+	})
+	return err
+}
+
+// ----------------------------------------------------------------------------
+
+// keep the message invisible to other consumers for the given seconds, from
+// now, up to the longest SQS allows
+func (in *queueInput) setVisibility(ctx context.Context, message types.Message, seconds int32) error {
+	if seconds > maxVisibilitySeconds {
+		seconds = maxVisibilitySeconds
+	}
+	_, err := in.client.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(in.queueURL),
+		ReceiptHandle:     message.ReceiptHandle,
+		VisibilityTimeout: seconds,
+	})
+	return err
+}
+
+// ----------------------------------------------------------------------------
+
+// the operation named by the message's operation attribute; "" when it has
+// none
+func operationAttribute(message types.Message) string {
+	attribute, ok := message.MessageAttributes[operation.Header]
+	if !ok || attribute.StringValue == nil {
+		return ""
+	}
+	return *attribute.StringValue
+}
+
+// ----------------------------------------------------------------------------
+
+// keep the message invisible to other consumers until the visibility context
+// is cancelled.  if the consumer is shutting down, make the message visible
+// again straight away.
+func (in *queueInput) extendVisibility(visibilityContext context.Context, message types.Message) {
+	period := time.Duration(in.visibilitySeconds-1) * time.Second
+	if period <= 0 {
+		period = time.Second
+	}
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-in.ctx.Done():
+			in.setVisibility(context.Background(), message, 0)
+			return
+		case <-visibilityContext.Done():
+			return
+		case <-ticker.C:
+			setVisibilityError := in.setVisibility(visibilityContext, message, in.visibilitySeconds)
+			if setVisibilityError != nil {
+				log.Log(4504, "messageId", aws.ToString(message.MessageId), "error", setVisibilityError)
+				return
+			}
 		}
 	}
-	return g2engine
 }
 
 // ----------------------------------------------------------------------------
 
-// TODO: update error handling
-func handleError(key int, err error, msg string) {
-	log.SetPrefix(fmt.Sprintf("[logID:%v]", key))
-	log.Printf("%#v\n", err)
-	fmt.Printf("[%v] %v", key, msg)
-	panic(err)
+// the URL of the queue: the URL itself, or looked up by its queue-name
+func queueURL(ctx context.Context, client *sqs.Client, urlString string) (string, error) {
+	u, err := url.Parse(urlString)
+	if err != nil {
+		return "", err
+	}
+	queueName := u.Query().Get("queue-name")
+	if queueName == "" {
+		return urlString, nil
+	}
+	output, err := client.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{QueueName: aws.String(queueName)})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(output.QueueUrl), nil
 }
@@ -0,0 +1,32 @@
+package sqs
+
+import (
+	"net/url"
+	"testing"
+)
+
+// ----------------------------------------------------------------------------
+// Test interface functions
+// ----------------------------------------------------------------------------
+
+func TestIsSQSURL(test *testing.T) {
+	testCases := map[string]bool{
+		"https://sqs.us-east-1.amazonaws.com/000000000000/my-queue":                                      true,
+		"https://us-west-2.queue.amazonaws.com/123456789012/my-queue.fifo":                               true,
+		"https://sqs.cn-north-1.amazonaws.com.cn/123456789012/my_queue":                                  true,
+		"http://localhost:4566/000000000000/my-queue":                                                    true,
+		"https://public-read-access.s3.amazonaws.com/TestDataSets/SenzingTruthSet/truth-set-3.0.0.jsonl": false,
+		"https://example.com/data/truth-set.jsonl":                                                       false,
+		"https://example.com/123456789012/truth-set.jsonl":                                               false,
+		"http://localhost:8080/files/000000000000/my-queue/records.jsonl":                                false,
+	}
+	for urlString, expected := range testCases {
+		u, err := url.Parse(urlString)
+		if err != nil {
+			test.Fatal(err)
+		}
+		if actual := IsSQSURL(u); actual != expected {
+			test.Errorf("IsSQSURL(%s) = %v, expected %v", urlString, actual, expected)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+/*
+The limit package bounds a run of load, for batch jobs and tests that drain a
+queue rather than consume it forever.  reading stops after a number of
+records, after a wall-clock duration or once no record has been read, nor is
+being worked on, for an idle period.  records already taken are finished and
+the run ends as if the input had been exhausted.
+*/
+package limit
@@ -0,0 +1,170 @@
+package limit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// why reading stopped, see Limiter.Reason
+const (
+	ReasonIdle        = "exit-when-idle"
+	ReasonMaxDuration = "max-duration"
+	ReasonMaxRecords  = "max-records"
+)
+
+// ----------------------------------------------------------------------------
+// Types
+// ----------------------------------------------------------------------------
+
+// A Limiter counts the records taken from an input and stops reading once a
+// limit is reached.  a nil Limiter has no limits.  it is safe for use by
+// concurrent workers.
+type Limiter struct {
+	cancel     context.CancelFunc
+	idleFor    time.Duration
+	maxRecords int64
+
+	lock         sync.Mutex
+	inFlight     int64
+	lastActivity time.Time
+	reason       string
+	taken        int64
+}
+
+// ----------------------------------------------------------------------------
+
+// New returns a limiter and a read context that is done once the first of
+// the limits is reached, or ctx is done.  a limit of zero is no limit;
+// maxRecords is the number of records taken and idleFor the time without
+// records to read or work on.
+func New(ctx context.Context, maxRecords int, maxDuration, idleFor time.Duration) (*Limiter, context.Context) {
+	readCtx, cancel := context.WithCancel(ctx)
+	limiter := &Limiter{
+		cancel:       cancel,
+		idleFor:      idleFor,
+		lastActivity: time.Now(),
+		maxRecords:   int64(maxRecords),
+	}
+	if maxDuration > 0 {
+		go limiter.watchDuration(readCtx, maxDuration)
+	}
+	if idleFor > 0 {
+		go limiter.watchIdle(readCtx)
+	}
+	return limiter, readCtx
+}
+
+// ----------------------------------------------------------------------------
+
+// Take reports whether a record that was read may be processed; it may not
+// once the maximum number of records has been taken.  the record that
+// reaches the maximum stops reading.  call Done once a record taken has been
+// processed.
+func (l *Limiter) Take() bool {
+	if l == nil {
+		return true
+	}
+	l.lock.Lock()
+	if l.maxRecords > 0 && l.taken >= l.maxRecords {
+		l.lock.Unlock()
+		return false
+	}
+	l.taken++
+	l.inFlight++
+	l.lastActivity = time.Now()
+	reachedMax := l.maxRecords > 0 && l.taken >= l.maxRecords
+	l.lock.Unlock()
+
+	if reachedMax {
+		l.stop(ReasonMaxRecords)
+	}
+	return true
+}
+
+// ----------------------------------------------------------------------------
+
+// Done marks a record taken as processed.
+func (l *Limiter) Done() {
+	if l == nil {
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.inFlight--
+	l.lastActivity = time.Now()
+}
+
+// ----------------------------------------------------------------------------
+
+// Close stops the limiter's timers, and reading, once the run is over.
+func (l *Limiter) Close() {
+	if l == nil {
+		return
+	}
+	l.cancel()
+}
+
+// ----------------------------------------------------------------------------
+
+// Reason returns the limit that stopped reading; "" when none has.
+func (l *Limiter) Reason() string {
+	if l == nil {
+		return ""
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.reason
+}
+
+// ----------------------------------------------------------------------------
+
+// stop reading for the given reason, unless reading has already stopped
+func (l *Limiter) stop(reason string) {
+	l.lock.Lock()
+	if len(l.reason) == 0 {
+		l.reason = reason
+	}
+	l.lock.Unlock()
+	l.cancel()
+}
+
+// ----------------------------------------------------------------------------
+
+// stop reading once the maximum duration has passed
+func (l *Limiter) watchDuration(readCtx context.Context, maxDuration time.Duration) {
+	timer := time.NewTimer(maxDuration)
+	defer timer.Stop()
+	select {
+	case <-readCtx.Done():
+	case <-timer.C:
+		l.stop(ReasonMaxDuration)
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+// stop reading once there have been no records to take, nor records in
+// flight, for the idle period
+func (l *Limiter) watchIdle(readCtx context.Context) {
+	wait := l.idleFor
+	for {
+		select {
+		case <-readCtx.Done():
+			return
+		case <-time.After(wait):
+		}
+		l.lock.Lock()
+		idle := time.Since(l.lastActivity)
+		busy := l.inFlight > 0
+		l.lock.Unlock()
+		if !busy && idle >= l.idleFor {
+			l.stop(ReasonIdle)
+			return
+		}
+		wait = l.idleFor - idle
+		if busy || wait <= 0 {
+			wait = l.idleFor
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package limit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// ----------------------------------------------------------------------------
+
+func TestTake_maxRecords(test *testing.T) {
+	limiter, readCtx := New(context.Background(), 2, 0, 0)
+	defer limiter.Close()
+	if !limiter.Take() || readCtx.Err() != nil {
+		test.Fatal("expected the first record to be taken")
+	}
+	if !limiter.Take() {
+		test.Fatal("expected the second record to be taken")
+	}
+	if readCtx.Err() == nil {
+		test.Error("expected reading to stop at the maximum")
+	}
+	if limiter.Take() {
+		test.Error("expected no more records to be taken")
+	}
+	if limiter.Reason() != ReasonMaxRecords {
+		test.Errorf("expected %s, got %q", ReasonMaxRecords, limiter.Reason())
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+func TestNew_maxDuration(test *testing.T) {
+	limiter, readCtx := New(context.Background(), 0, 10*time.Millisecond, 0)
+	defer limiter.Close()
+	select {
+	case <-readCtx.Done():
+	case <-time.After(time.Second):
+		test.Fatal("expected reading to stop after the maximum duration")
+	}
+	if limiter.Reason() != ReasonMaxDuration {
+		test.Errorf("expected %s, got %q", ReasonMaxDuration, limiter.Reason())
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+func TestNew_idle(test *testing.T) {
+	limiter, readCtx := New(context.Background(), 0, 0, 50*time.Millisecond)
+	defer limiter.Close()
+
+	// a record in flight keeps the run going past the idle period
+	limiter.Take()
+	time.Sleep(100 * time.Millisecond)
+	if readCtx.Err() != nil {
+		test.Fatal("expected a record in flight to keep reading going")
+	}
+	limiter.Done()
+	select {
+	case <-readCtx.Done():
+	case <-time.After(time.Second):
+		test.Fatal("expected reading to stop once idle")
+	}
+	if limiter.Reason() != ReasonIdle {
+		test.Errorf("expected %s, got %q", ReasonIdle, limiter.Reason())
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+func TestNilLimiter(test *testing.T) {
+	var limiter *Limiter
+	if !limiter.Take() {
+		test.Error("expected a nil limiter to take every record")
+	}
+	limiter.Done()
+	limiter.Close()
+	if limiter.Reason() != "" {
+		test.Error("expected no reason from a nil limiter")
+	}
+}
@@ -2,28 +2,101 @@ package loader
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
-	"runtime"
 	"runtime/debug"
 	"time"
 
+	"github.com/roncewind/load/engine"
+	"github.com/roncewind/load/health"
 	"github.com/roncewind/load/input"
+	"github.com/roncewind/load/limit"
+	"github.com/roncewind/load/loaderror"
+	"github.com/roncewind/load/logging"
+	"github.com/roncewind/load/metrics"
+	"github.com/roncewind/load/output"
+	"github.com/roncewind/load/redact"
+	"github.com/roncewind/load/redo"
+
+	// the inputs available, see input.Register
+	_ "github.com/roncewind/load/input/file"
+	_ "github.com/roncewind/load/input/kafka"
+	_ "github.com/roncewind/load/input/nats"
+	_ "github.com/roncewind/load/input/rabbitmq"
+	_ "github.com/roncewind/load/input/sqs"
 )
 
+var log = logging.New("loader", map[int]string{
+	1101: "Build information",
+	1102: "Threads by process",
+	1103: "Engine statistics",
+	2101: "Serving metrics and health probes",
+	2102: "Stopping; records in flight have the grace period to finish",
+	2103: "Load stopped",
+	2104: "Stopped reading at a limit",
+	2105: "Built the engine configuration for the database",
+	3101: "Unable to read the build information",
+	3102: "Unable to list processes",
+	3103: "Unable to read the engine statistics",
+	3104: "The engine configuration JSON is given, so the database URL is not used",
+	3105: "Records are still in flight, so the engine and outputs are left open",
+	4101: "The HTTP server stopped",
+})
+
+// what stopped a run that was stopped by SIGINT or SIGTERM, in the summary
+const stoppedBySignal = "signal"
+
+// how long to wait for the inputs to clean up once the grace period is over
+var shutdownTimeout = 5 * time.Second
+
 // ----------------------------------------------------------------------------
 // Types
 // ----------------------------------------------------------------------------
 
+// LoaderImpl loads records from the input URLs with a single Senzing engine,
+// the records of every input sharing the workers.  when no Engine is given,
+// one is created from the engine configuration JSON, the database URL and
+// Senzing directories, or the gRPC URL, and destroyed at the end of the load;
+// unless records are still in flight when the grace period is over, when the
+// process is expected to exit.
+// a given Engine is used as it is; the with-info output is then up to it.
 type LoaderImpl struct {
+	ConfigPath                string
+	CSVMapping                input.CSVMapping
+	DatabaseURL               string
+	DeadLetterURL             string
+	DryRun                    bool
+	Engine                    engine.Engine
 	EngineConfigJson          string
 	EngineLogLevel            int
-	InputURL                  string
+	ExitWhenIdle              time.Duration
+	GracePeriodInSeconds      int
+	GrpcURL                   string
+	HTTPPort                  int
+	InputFileType             string
+	InputURLs                 []string
 	LogLevel                  string
+	MaxDuration               time.Duration
+	MaxRecords                int
 	NumberOfWorkers           int
+	OutputURL                 string
+	RedoMode                  string
+	RedoWorkers               int
+	ResourcePath              string
+	SummaryFile               string
+	SupportPath               string
+	ValidationMode            string
 	VisibilityPeriodInSeconds int
+	WithInfo                  bool
 }
 
+// what a run does with the engine; it reads until the read context is done
+// and works on what it read with the context.  the signal context is done on
+// SIGINT or SIGTERM.
+type work func(ctx, signalCtx, readCtx context.Context, limiter *limit.Limiter, g2engine engine.Engine, deadLetterWriter output.Writer) error
+
 // ----------------------------------------------------------------------------
 
 // Check at compile time that the implementation adheres to the interface.
@@ -31,92 +104,344 @@ var _ Loader = (*LoaderImpl)(nil)
 
 // ----------------------------------------------------------------------------
 
-func (l *LoaderImpl) Load(ctx context.Context) bool {
+// Load records from the input URL.  the error returned, if any, is one of the
+// loaderror categories; see loaderror.ExitCode.
+//
+// when the context is done, eg. on SIGTERM, no more records are read and the
+// records in flight are given the grace period to finish before they are
+// abandoned.  reading also stops, and the run ends as if the input had been
+// exhausted, once the maximum number of records or duration is reached or
+// the input has been idle for the ExitWhenIdle period.  redo records are
+// processed alongside, or afterwards, as the RedoMode asks.  a summary of the
+// run is printed to stdout at the end, and written to the summary file when
+// one is given.
+func (l *LoaderImpl) Load(ctx context.Context) error {
+	if len(l.RedoMode) > 0 {
+		if err := redo.CheckMode(l.RedoMode); err != nil {
+			return loaderror.ConfigurationError{Err: err}
+		}
+	}
+	return l.run(ctx, l.readInput)
+}
+
+// ----------------------------------------------------------------------------
+
+// Redo processes redo records, on their own, until the context is done or a
+// limit is reached; the maximum number of records, or duration, or the
+// ExitWhenIdle period without redo records.  the summary is as for Load.
+func (l *LoaderImpl) Redo(ctx context.Context) error {
+	if l.DryRun {
+		return loaderror.ConfigurationError{Err: errors.New("redo records cannot be processed in a dry run")}
+	}
+	return l.run(ctx, l.processRedo)
+}
 
+// ----------------------------------------------------------------------------
+
+// do the work of a run, printing, and writing, the summary at the end
+func (l *LoaderImpl) run(ctx context.Context, w work) error {
+	if len(l.LogLevel) > 0 {
+		if err := logging.SetLevel(l.LogLevel); err != nil {
+			return loaderror.ConfigurationError{Err: err}
+		}
+	}
+
+	metrics.Reset()
+	startTime := time.Now()
+	stoppedBy, err := l.load(ctx, w)
+	summary := newSummary(startTime, time.Now(), stoppedBy, err)
+	summary.print(os.Stdout)
+	if len(l.SummaryFile) > 0 {
+		writeErr := summary.writeFile(l.SummaryFile)
+		if writeErr != nil && err == nil {
+			err = writeErr
+		}
+	}
+	return err
+}
+
+// ----------------------------------------------------------------------------
+
+// do the work with the engine, stopping once the context is done or a limit
+// is reached; what stopped reading early, if anything, is returned
+func (l *LoaderImpl) load(ctx context.Context, w work) (string, error) {
 	logOSInfo()
 	logBuildInfo()
-	logStats()
 
-	ticker := time.NewTicker(1 * time.Minute)
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				logStats()
-			}
+	if l.HTTPPort > 0 {
+		stopServer, err := serveHTTP(l.HTTPPort)
+		if err != nil {
+			return "", err
 		}
+		defer stopServer()
+	}
+
+	gracePeriod := time.Duration(l.GracePeriodInSeconds) * time.Second
+	workCtx, cancelWork := withGracePeriod(ctx, gracePeriod)
+	defer cancelWork()
+
+	g2engine, deadLetterWriter, closeOutputs, err := l.openOutputs(workCtx)
+	if err != nil {
+		return "", err
+	}
+	// workers still in flight may be inside the engine; it is not destroyed
+	// under them, the process is about to exit
+	inFlight := false
+	defer func() {
+		if inFlight {
+			log.Log(3105)
+			return
+		}
+		closeOutputs()
 	}()
 
-	return input.Read(ctx, l.InputURL, l.LogLevel, l.EngineConfigJson, l.EngineLogLevel, l.NumberOfWorkers, l.VisibilityPeriodInSeconds)
+	limiter, readCtx := limit.New(ctx, l.MaxRecords, l.MaxDuration, l.ExitWhenIdle)
+	defer limiter.Close()
+
+	result := make(chan error, 1)
+	go func() {
+		result <- w(workCtx, ctx, readCtx, limiter, g2engine, deadLetterWriter)
+	}()
+
+	select {
+	case err = <-result:
+	case <-workCtx.Done():
+		// the grace period is over; give the inputs a moment to requeue what
+		// they still hold and release the engine
+		select {
+		case err = <-result:
+		case <-time.After(shutdownTimeout):
+			inFlight = true
+			err = fmt.Errorf("records were still in flight when the %v grace period ended", gracePeriod)
+		}
+	}
+
+	if ctx.Err() != nil {
+		log.Log(2103, "reason", ctx.Err())
+		return stoppedBySignal, err
+	}
+	stoppedBy := limiter.Reason()
+	if len(stoppedBy) > 0 {
+		log.Log(2104, "limit", stoppedBy)
+	}
+	return stoppedBy, err
 }
 
 // ----------------------------------------------------------------------------
 
-func logBuildInfo() {
-	buildInfo, ok := debug.ReadBuildInfo()
-	fmt.Println("---------------------------------------------------------------")
-	if ok {
-		fmt.Println("GoVersion:", buildInfo.GoVersion)
-		fmt.Println("Path:", buildInfo.Path)
-		fmt.Println("Main.Path:", buildInfo.Main.Path)
-		fmt.Println("Main.Version:", buildInfo.Main.Version)
-	} else {
-		fmt.Println("Unable to read build info.")
+// read and load the records from the input.  redo records are processed
+// alongside, with their own workers, or once the input has been read, as the
+// redo mode asks; either way they are processed until none are left, unless
+// the run is stopped by a signal.  a dry run processes none.
+func (l *LoaderImpl) readInput(ctx, signalCtx, readCtx context.Context, limiter *limit.Limiter, g2engine engine.Engine, deadLetterWriter output.Writer) error {
+	redoMode := l.RedoMode
+	if g2engine == nil || len(redoMode) == 0 {
+		redoMode = redo.ModeOff
 	}
+
+	stopRedo := func() {}
+	if redoMode == redo.ModeConcurrent {
+		redoCtx, cancelRedo := context.WithCancel(signalCtx)
+		redoDone := make(chan struct{})
+		go func() {
+			defer close(redoDone)
+			redo.Process(ctx, redoCtx, g2engine, l.RedoWorkers, nil, false)
+		}()
+		stopRedo = func() {
+			cancelRedo()
+			<-redoDone
+		}
+	}
+	err := input.Read(ctx, readCtx, input.ReadOptions{
+		CSVMapping:                l.CSVMapping,
+		DeadLetterWriter:          deadLetterWriter,
+		Engine:                    g2engine,
+		FileType:                  l.InputFileType,
+		InputURLs:                 l.InputURLs,
+		Limiter:                   limiter,
+		NumberOfWorkers:           l.NumberOfWorkers,
+		ValidationMode:            l.ValidationMode,
+		VisibilityPeriodInSeconds: l.VisibilityPeriodInSeconds,
+	})
+	stopRedo()
+
+	if redoMode != redo.ModeOff && err == nil && signalCtx.Err() == nil {
+		redo.Process(ctx, signalCtx, g2engine, l.NumberOfWorkers, nil, true)
+	}
+	return err
 }
 
 // ----------------------------------------------------------------------------
 
-func logOSInfo() {
-	cmd := exec.Command("ps", "-eo", "nlwp,cmd")
-	stdout, err := cmd.Output()
+// process redo records, on their own, until reading stops
+func (l *LoaderImpl) processRedo(ctx, signalCtx, readCtx context.Context, limiter *limit.Limiter, g2engine engine.Engine, deadLetterWriter output.Writer) error {
+	health.InputReady()
+	redo.Process(ctx, readCtx, g2engine, l.NumberOfWorkers, limiter, false)
+	return nil
+}
 
+// ----------------------------------------------------------------------------
+
+// open the engine records are loaded with, and the writer for the records
+// that cannot be loaded.  a dry run has neither.  the returned function logs
+// the engine's statistics and releases what was opened.
+func (l *LoaderImpl) openOutputs(ctx context.Context) (engine.Engine, output.Writer, func(), error) {
+	health.EngineReady(l.DryRun)
+	if l.DryRun {
+		return nil, nil, func() {}, nil
+	}
+
+	var closers []func()
+	closeOutputs := func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i]()
+		}
+	}
+
+	// records that cannot be loaded go to the dead letter URL, when one is given
+	var deadLetterWriter output.Writer
+	if len(l.DeadLetterURL) > 0 {
+		writer, err := output.NewWriter(ctx, l.DeadLetterURL)
+		if err != nil {
+			closeOutputs()
+			return nil, nil, nil, loaderror.ConnectionError{Err: fmt.Errorf("unable to write to the dead letter URL: %w", redact.Error(err))}
+		}
+		closers = append(closers, func() { writer.Close() })
+		deadLetterWriter = writer
+	}
+
+	g2engine := l.Engine
+	if g2engine == nil {
+		// with-info results go to the output URL, or stdout when none is
+		// given; a given engine has its own
+		var infoWriter output.Writer
+		if l.WithInfo || len(l.OutputURL) > 0 {
+			writer, err := output.NewWriter(ctx, l.OutputURL)
+			if err != nil {
+				closeOutputs()
+				return nil, nil, nil, loaderror.ConnectionError{Err: fmt.Errorf("unable to write to the output URL: %w", redact.Error(err))}
+			}
+			closers = append(closers, func() { writer.Close() })
+			infoWriter = writer
+		}
+		engineConfigJson, err := l.engineConfigJSON(ctx)
+		if err != nil {
+			closeOutputs()
+			return nil, nil, nil, err
+		}
+		g2, err := engine.New(ctx, l.GrpcURL, engineConfigJson, l.EngineLogLevel, infoWriter)
+		if err != nil {
+			closeOutputs()
+			return nil, nil, nil, err
+		}
+		closers = append(closers, func() { g2.Destroy(context.Background()) })
+		g2engine = g2
+	}
+	health.EngineReady(true)
+	closers = append(closers, func() {
+		health.EngineReady(false)
+		logStats(g2engine)
+	})
+	return g2engine, deadLetterWriter, closeOutputs, nil
+}
+
+// ----------------------------------------------------------------------------
+
+// the engine configuration JSON; the one given, or one built for the database
+// URL once the database has been found to be reachable.  an engine on a
+// Senzing gRPC server has its own configuration.
+func (l *LoaderImpl) engineConfigJSON(ctx context.Context) (string, error) {
+	if len(l.GrpcURL) > 0 {
+		return "", nil
+	}
+	if len(l.EngineConfigJson) > 0 {
+		if len(l.DatabaseURL) > 0 {
+			log.Log(3104)
+		}
+		return l.EngineConfigJson, nil
+	}
+	if len(l.DatabaseURL) == 0 {
+		return "", loaderror.ConfigurationError{Err: errors.New("a database URL, engine configuration JSON or gRPC URL is required to load records")}
+	}
+	engineConfigJson, err := engine.ConfigJSON(l.DatabaseURL, l.ConfigPath, l.ResourcePath, l.SupportPath)
 	if err != nil {
-		fmt.Println(err.Error())
-		return
+		return "", err
 	}
+	err = engine.CheckDatabase(ctx, l.DatabaseURL)
+	if err != nil {
+		return "", err
+	}
+	log.Log(2105, "database", redact.URL(l.DatabaseURL))
+	return engineConfigJson, nil
+}
 
-	fmt.Println("DEBUG OS:", string(stdout))
+// ----------------------------------------------------------------------------
+
+// a context for the work on records in flight; it is cancelled the grace
+// period after the given context is done.
+func withGracePeriod(ctx context.Context, gracePeriod time.Duration) (context.Context, context.CancelFunc) {
+	workCtx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-workCtx.Done():
+			return
+		case <-ctx.Done():
+		}
+		log.Log(2102, "gracePeriod", gracePeriod.String())
+		timer := time.NewTimer(gracePeriod)
+		defer timer.Stop()
+		select {
+		case <-workCtx.Done():
+		case <-timer.C:
+			cancel()
+		}
+	}()
+	return workCtx, cancel
+}
+
+// ----------------------------------------------------------------------------
+
+func logBuildInfo() {
+	buildInfo, ok := debug.ReadBuildInfo()
+	if !ok {
+		log.Log(3101)
+		return
+	}
+	log.Log(1101,
+		"goVersion", buildInfo.GoVersion,
+		"path", buildInfo.Path,
+		"mainPath", buildInfo.Main.Path,
+		"mainVersion", buildInfo.Main.Version,
+	)
 }
 
 // ----------------------------------------------------------------------------
 
-func logStats() {
-	cpus := runtime.NumCPU()
-	goMaxProc := runtime.GOMAXPROCS(0)
-	goRoutines := runtime.NumGoroutine()
-	cgoCalls := runtime.NumCgoCall()
-	var memStats runtime.MemStats
-	runtime.ReadMemStats(&memStats)
-	var gcStats debug.GCStats
-	debug.ReadGCStats(&gcStats)
-
-	// fmt.Println("---------------------------------------------------------------")
-	// fmt.Println("Time:", time.Now())
-	// fmt.Println("CPUs:", cpus)
-	// fmt.Println("GOMAXPROC:", goMaxProc)
-	// fmt.Println("Go routines:", goRoutines)
-	// fmt.Println("CGO calls:", cgoCalls)
-	// fmt.Println("Num GC:", memStats.NumGC)
-	// fmt.Println("GCSys:", memStats.GCSys)
-	// fmt.Println("GC pause total:", gcStats.PauseTotal)
-	// fmt.Println("LastGC:", gcStats.LastGC)
-	// fmt.Println("HeapAlloc:", memStats.HeapAlloc)
-	// fmt.Println("NextGC:", memStats.NextGC)
-	// fmt.Println("CPU fraction used by GC:", memStats.GCCPUFraction)
-
-	fmt.Println("---------------------------------------------------------------")
-	printCSV(">>>", "Time", "CPUs", "GOMAXPROC", "Go routines", "CGO calls", "Num GC", "GC pause total", "LastGC", "TotalAlloc", "HeapAlloc", "NextGC", "GCSys", "HeapSys", "StackSys", "Sys - total OS bytes", "CPU fraction used by GC")
-	printCSV(">>>", time.Now(), cpus, goMaxProc, goRoutines, cgoCalls, memStats.NumGC, gcStats.PauseTotal, gcStats.LastGC, memStats.TotalAlloc, memStats.HeapAlloc, memStats.NextGC, memStats.GCSys, memStats.HeapSys, memStats.StackSys, memStats.Sys, memStats.GCCPUFraction)
+// log the engine's workload statistics at the end of a load
+func logStats(g2engine engine.Engine) {
+	if !log.Enabled(1103) {
+		return
+	}
+	stats, err := g2engine.Stats(context.Background())
+	if err != nil {
+		log.Log(3103, "error", err)
+		return
+	}
+	log.Log(1103, "stats", stats)
 }
 
 // ----------------------------------------------------------------------------
 
-func printCSV(fields ...any) {
-	for _, field := range fields {
-		fmt.Print(field, ",")
+func logOSInfo() {
+	if !log.Enabled(1102) {
+		return
+	}
+	cmd := exec.Command("ps", "-eo", "nlwp,cmd")
+	stdout, err := cmd.Output()
+	if err != nil {
+		log.Log(3102, "error", err)
+		return
 	}
-	fmt.Println("")
+	// command lines may hold credentials, eg. an --input-url with a password
+	log.Log(1102, "ps", redact.String(string(stdout)))
 }
@@ -0,0 +1,146 @@
+package loader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/roncewind/load/engine"
+	"github.com/roncewind/load/health"
+	"github.com/roncewind/load/limit"
+	"github.com/roncewind/load/metrics"
+	"github.com/roncewind/load/output"
+	"github.com/roncewind/load/redo"
+)
+
+// ----------------------------------------------------------------------------
+// Test helpers
+// ----------------------------------------------------------------------------
+
+// an in-memory engine that keeps the records added to it
+type memoryEngine struct {
+	engine.Engine
+	records sync.Map
+	redo    int64
+}
+
+func (engine *memoryEngine) AddRecord(ctx context.Context, dataSourceCode, recordID, jsonData string) error {
+	engine.records.Store(dataSourceCode+":"+recordID, jsonData)
+	atomic.AddInt64(&engine.redo, 1)
+	return nil
+}
+
+// each record added leaves a redo record behind
+func (engine *memoryEngine) ProcessRedoRecord(ctx context.Context) (string, error) {
+	if atomic.AddInt64(&engine.redo, -1) < 0 {
+		atomic.AddInt64(&engine.redo, 1)
+		return "", nil
+	}
+	return `{"DATA_SOURCE": "TEST", "RECORD_ID": "1"}`, nil
+}
+
+func (engine *memoryEngine) Stats(ctx context.Context) (string, error) {
+	return "{}", nil
+}
+
+// ----------------------------------------------------------------------------
+// Test interface functions
+// ----------------------------------------------------------------------------
+
+func TestLoad_engine(test *testing.T) {
+	name := filepath.Join(test.TempDir(), "records.jsonl")
+	records := `{"DATA_SOURCE": "TEST", "RECORD_ID": "1"}` + "\n" + `{"DATA_SOURCE": "TEST", "RECORD_ID": "2"}`
+	if err := os.WriteFile(name, []byte(records), 0o600); err != nil {
+		test.Fatal(err)
+	}
+	g2engine := &memoryEngine{}
+	outputName := filepath.Join(test.TempDir(), "info.jsonl")
+	loader := &LoaderImpl{
+		Engine:          g2engine,
+		InputURLs:       []string{"file://" + name},
+		NumberOfWorkers: 1,
+		OutputURL:       "file://" + outputName,
+		ValidationMode:  "lenient",
+		WithInfo:        true,
+	}
+	if err := loader.Load(context.TODO()); err != nil {
+		test.Fatal(err)
+	}
+	for _, key := range []string{"TEST:1", "TEST:2"} {
+		if _, ok := g2engine.records.Load(key); !ok {
+			test.Errorf("expected %s to be loaded with the given engine", key)
+		}
+	}
+
+	// the given engine has its own with-info output
+	if _, err := os.Stat(outputName); !os.IsNotExist(err) {
+		test.Errorf("expected no output file, got %v", err)
+	}
+}
+
+func TestWithGracePeriod(test *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	workCtx, cancelWork := withGracePeriod(ctx, 50*time.Millisecond)
+	defer cancelWork()
+
+	cancel()
+	select {
+	case <-workCtx.Done():
+		test.Fatal("expected the work context to outlive the context by the grace period")
+	case <-time.After(20 * time.Millisecond):
+	}
+	select {
+	case <-workCtx.Done():
+	case <-time.After(time.Second):
+		test.Fatal("expected the work context to be done after the grace period")
+	}
+}
+
+func TestLoad_inFlight(test *testing.T) {
+	defer func(timeout time.Duration) { shutdownTimeout = timeout }(shutdownTimeout)
+	shutdownTimeout = 10 * time.Millisecond
+
+	// a worker stuck in the engine past the grace period
+	stuck := make(chan struct{})
+	defer close(stuck)
+	work := func(ctx, signalCtx, readCtx context.Context, limiter *limit.Limiter, g2engine engine.Engine, deadLetterWriter output.Writer) error {
+		<-stuck
+		return nil
+	}
+	health.InputReady()
+	loader := &LoaderImpl{Engine: &memoryEngine{}}
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	if _, err := loader.load(ctx, work); err == nil {
+		test.Error("expected an error for the records in flight")
+	}
+	if err := health.Ready(); err != nil {
+		test.Errorf("expected the engine to be left open while a worker is using it, got %v", err)
+	}
+}
+
+func TestLoad_redoAfter(test *testing.T) {
+	name := filepath.Join(test.TempDir(), "records.jsonl")
+	records := `{"DATA_SOURCE": "TEST", "RECORD_ID": "1"}` + "\n" + `{"DATA_SOURCE": "TEST", "RECORD_ID": "2"}`
+	if err := os.WriteFile(name, []byte(records), 0o600); err != nil {
+		test.Fatal(err)
+	}
+	g2engine := &memoryEngine{}
+	loader := &LoaderImpl{
+		Engine:          g2engine,
+		InputURLs:       []string{"file://" + name},
+		NumberOfWorkers: 1,
+		RedoMode:        redo.ModeAfter,
+		ValidationMode:  "lenient",
+	}
+	if err := loader.Load(context.TODO()); err != nil {
+		test.Fatal(err)
+	}
+	if redoRecords := metrics.Snapshot().Redo; redoRecords.Loaded != 2 {
+		test.Errorf("expected 2 redo records processed after loading, got %+v", redoRecords)
+	}
+}
@@ -7,7 +7,8 @@ import "context"
 // ----------------------------------------------------------------------------
 
 type Loader interface {
-	Load(context.Context) bool
+	Load(context.Context) error
+	Redo(context.Context) error
 }
 
 // mover is 6601:  https://github.com/Senzing/knowledge-base/blob/main/lists/senzing-product-ids.md
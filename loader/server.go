@@ -0,0 +1,61 @@
+package loader
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/roncewind/load/health"
+	"github.com/roncewind/load/loaderror"
+	"github.com/roncewind/load/metrics"
+)
+
+// ----------------------------------------------------------------------------
+
+// serve /metrics, /healthz and /readyz over HTTP on the given port.  the
+// returned function stops the server.
+func serveHTTP(port int) (func(), error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return nil, loaderror.ConfigurationError{Err: fmt.Errorf("unable to serve HTTP on port %d: %w", port, err)}
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	mux.HandleFunc("/healthz", probe(health.Live))
+	mux.HandleFunc("/readyz", probe(health.Ready))
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		err := server.Serve(listener)
+		if err != nil && err != http.ErrServerClosed {
+			log.Log(4101, "error", err)
+		}
+	}()
+	log.Log(2101, "address", listener.Addr().String())
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}, nil
+}
+
+// ----------------------------------------------------------------------------
+
+// a probe handler; 200 when the check passes, otherwise 503 with the reason
+func probe(check func() error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if err := check(); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, err)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	}
+}
@@ -0,0 +1,113 @@
+package loader
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/roncewind/load/loaderror"
+	"github.com/roncewind/load/metrics"
+	"github.com/roncewind/load/redact"
+	"github.com/roncewind/load/validate"
+)
+
+// the number of most frequent error codes in the summary
+const topErrorCodes = 10
+
+// ----------------------------------------------------------------------------
+// Types
+// ----------------------------------------------------------------------------
+
+// Summary describes a run of load, for people and for CI pipelines to assert
+// on.
+type Summary struct {
+	StartTime       time.Time                          `json:"startTime"`
+	EndTime         time.Time                          `json:"endTime"`
+	DurationSeconds float64                            `json:"durationSeconds"`
+	Records         metrics.Counts                     `json:"records"`
+	DataSources     map[string]metrics.Counts          `json:"dataSources"`
+	Inputs          map[string]metrics.Counts          `json:"inputs,omitempty"`
+	Operations      map[string]metrics.OperationCounts `json:"operations,omitempty"`
+	RedoRecords     *metrics.Counts                    `json:"redoRecords,omitempty"`
+	Throughput      Throughput                         `json:"throughput"`
+	TopErrorCodes   []metrics.ErrorCount               `json:"topErrorCodes"`
+	Validation      *validate.Result                   `json:"validation,omitempty"`
+	Error           string                             `json:"error,omitempty"`
+	StoppedBy       string                             `json:"stoppedBy,omitempty"`
+	ExitCode        int                                `json:"exitCode"`
+}
+
+// Throughput is the number of records read and loaded per second.
+type Throughput struct {
+	Read   float64 `json:"readPerSecond"`
+	Loaded float64 `json:"loadedPerSecond"`
+}
+
+// ----------------------------------------------------------------------------
+
+// summarize the records counted since startTime, what stopped reading early
+// and the error, if any, that stopped the run
+func newSummary(startTime, endTime time.Time, stoppedBy string, err error) Summary {
+	totals := metrics.Snapshot()
+	duration := endTime.Sub(startTime)
+	summary := Summary{
+		StartTime:       startTime.UTC(),
+		EndTime:         endTime.UTC(),
+		DurationSeconds: duration.Seconds(),
+		Records:         totals.Counts,
+		DataSources:     totals.DataSources,
+		Inputs:          totals.Inputs,
+		Operations:      totals.Operations,
+		TopErrorCodes:   totals.Errors,
+		Validation:      totals.Validation,
+		StoppedBy:       stoppedBy,
+		ExitCode:        loaderror.ExitCode(err),
+	}
+	if totals.Redo != (metrics.Counts{}) {
+		summary.RedoRecords = &totals.Redo
+	}
+	if len(summary.TopErrorCodes) > topErrorCodes {
+		summary.TopErrorCodes = summary.TopErrorCodes[:topErrorCodes]
+	}
+	if duration > 0 {
+		summary.Throughput.Read = float64(totals.Read) / duration.Seconds()
+		summary.Throughput.Loaded = float64(totals.Loaded) / duration.Seconds()
+	}
+	if err != nil {
+		summary.Error = redact.String(err.Error())
+	}
+	return summary
+}
+
+// ----------------------------------------------------------------------------
+
+// print the summary as indented JSON
+func (s Summary) print(w io.Writer) error {
+	encoded, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(encoded))
+	return err
+}
+
+// ----------------------------------------------------------------------------
+
+// write the summary to the given file as JSON, replacing what is there
+func (s Summary) writeFile(fileName string) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return fmt.Errorf("unable to write the summary file: %w", err)
+	}
+	err = s.print(file)
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write the summary file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+/*
+The loaderror package holds the categories of error that stop a load and the
+process exit code each category maps to:
+
+	0  success
+	1  unexpected failure
+	2  configuration; a missing or invalid option or URL
+	3  engine; the Senzing engine could not be created or initialized
+	4  connection; the input or a queue could not be reached or opened
+	5  input; the input failed while records were being read
+*/
+package loaderror
@@ -0,0 +1,77 @@
+package loaderror
+
+import (
+	"errors"
+)
+
+// process exit codes, see the package documentation
+const (
+	ExitSuccess       = 0
+	ExitFailure       = 1
+	ExitConfiguration = 2
+	ExitEngine        = 3
+	ExitConnection    = 4
+	ExitInput         = 5
+)
+
+// ----------------------------------------------------------------------------
+// Types
+// ----------------------------------------------------------------------------
+
+// ConfigurationError is a missing or invalid option or URL.
+type ConfigurationError struct {
+	Err error
+}
+
+func (err ConfigurationError) Error() string { return err.Err.Error() }
+func (err ConfigurationError) Unwrap() error { return err.Err }
+
+// EngineError is a failure to create or initialize the Senzing engine.
+type EngineError struct {
+	Err error
+}
+
+func (err EngineError) Error() string { return err.Err.Error() }
+func (err EngineError) Unwrap() error { return err.Err }
+
+// ConnectionError is a failure to reach or open the input, or a queue.
+type ConnectionError struct {
+	Err error
+}
+
+func (err ConnectionError) Error() string { return err.Err.Error() }
+func (err ConnectionError) Unwrap() error { return err.Err }
+
+// InputError is a failure of the input while records are being read.
+type InputError struct {
+	Err error
+}
+
+func (err InputError) Error() string { return err.Err.Error() }
+func (err InputError) Unwrap() error { return err.Err }
+
+// ----------------------------------------------------------------------------
+
+// ExitCode returns the process exit code for the category of the error.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitSuccess
+	}
+	var (
+		configurationError ConfigurationError
+		engineError        EngineError
+		connectionError    ConnectionError
+		inputError         InputError
+	)
+	switch {
+	case errors.As(err, &configurationError):
+		return ExitConfiguration
+	case errors.As(err, &engineError):
+		return ExitEngine
+	case errors.As(err, &connectionError):
+		return ExitConnection
+	case errors.As(err, &inputError):
+		return ExitInput
+	}
+	return ExitFailure
+}
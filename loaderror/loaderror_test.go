@@ -0,0 +1,32 @@
+package loaderror
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// ----------------------------------------------------------------------------
+// Test interface functions
+// ----------------------------------------------------------------------------
+
+func TestExitCode(test *testing.T) {
+	cause := errors.New("cause")
+	testCases := []struct {
+		err      error
+		expected int
+	}{
+		{nil, ExitSuccess},
+		{cause, ExitFailure},
+		{ConfigurationError{Err: cause}, ExitConfiguration},
+		{EngineError{Err: cause}, ExitEngine},
+		{ConnectionError{Err: cause}, ExitConnection},
+		{InputError{Err: cause}, ExitInput},
+		{fmt.Errorf("wrapped: %w", EngineError{Err: cause}), ExitEngine},
+	}
+	for _, testCase := range testCases {
+		if actual := ExitCode(testCase.err); actual != testCase.expected {
+			test.Errorf("ExitCode(%v) = %d, expected %d", testCase.err, actual, testCase.expected)
+		}
+	}
+}
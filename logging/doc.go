@@ -0,0 +1,12 @@
+/*
+The logging package writes structured log lines for every part of load.  each
+line is a JSON object carrying a Senzing message ID, its level, the component
+that logged it and, where there is one, the record it is about, eg.
+
+	{"time":"...","level":"ERROR","text":"Unable to add the record","id":"senzing-62014401","component":"input/rabbitmq","messageId":"...","record":{"dataSource":"TEST","recordId":"1"},"error":"..."}
+
+as with other Senzing tools, a message's level follows from its number: 0-999
+are TRACE, 1000s DEBUG, 2000s INFO, 3000s WARN, 4000s ERROR, 5000s FATAL and
+6000s PANIC.  the level logged at is set with SetLevel, from --log-level.
+*/
+package logging
@@ -0,0 +1,155 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/roncewind/load/redact"
+	senzinglogging "github.com/senzing/go-logging/logging"
+	"golang.org/x/exp/slog"
+)
+
+// load is 6201:  https://github.com/Senzing/knowledge-base/blob/main/lists/senzing-product-ids.md
+const messageIDFormat = "senzing-6201%04d"
+
+var (
+	leveler = new(slog.LevelVar)
+	base    atomic.Pointer[slog.Logger]
+
+	// the lowest message number of each level, highest first
+	levelBounds []int
+)
+
+// ----------------------------------------------------------------------------
+// Types
+// ----------------------------------------------------------------------------
+
+// A Logger logs the messages of a single component.
+type Logger struct {
+	attrs     []any
+	component string
+	messages  map[int]string
+}
+
+// ----------------------------------------------------------------------------
+
+// New returns a logger for the given component, eg. "input/sqs", whose
+// messages are the text logged for each message number.
+func New(component string, messages map[int]string) *Logger {
+	return &Logger{
+		component: component,
+		messages:  messages,
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+// SetLevel sets the level logged at, one of TRACE, DEBUG, INFO, WARN, ERROR,
+// FATAL or PANIC, in any case.
+func SetLevel(levelName string) error {
+	level, ok := senzinglogging.TextToLevelMap[strings.ToUpper(levelName)]
+	if !ok {
+		return fmt.Errorf("unknown log level %q", levelName)
+	}
+	leveler.Set(level)
+	return nil
+}
+
+// ----------------------------------------------------------------------------
+
+// SetOutput sends log lines to the given writer, rather than stderr.
+func SetOutput(w io.Writer) {
+	options := senzinglogging.SlogHandlerOptions(leveler)
+	options.ReplaceAttr = redactAttr(options.ReplaceAttr)
+	base.Store(slog.New(slog.NewJSONHandler(w, options)))
+}
+
+// ----------------------------------------------------------------------------
+
+// Record is the context of the record a message is about.
+func Record(dataSource, recordID string) slog.Attr {
+	return slog.Group("record", "dataSource", dataSource, "recordId", recordID)
+}
+
+// ----------------------------------------------------------------------------
+
+// Enabled reports whether the given message would be logged.
+func (l *Logger) Enabled(messageNumber int) bool {
+	return base.Load().Enabled(context.Background(), level(messageNumber))
+}
+
+// ----------------------------------------------------------------------------
+
+// Log logs the given message with key-value pairs, or slog.Attrs, adding
+// context to it, eg.
+//
+//	log.Log(4401, "messageId", id, logging.Record(dataSource, recordID), "error", err)
+func (l *Logger) Log(messageNumber int, args ...any) {
+	text, ok := l.messages[messageNumber]
+	if !ok {
+		text = fmt.Sprintf("Unknown message %d", messageNumber)
+	}
+	attrs := make([]any, 0, 4+len(l.attrs)+len(args))
+	attrs = append(attrs, "id", fmt.Sprintf(messageIDFormat, messageNumber), "component", l.component)
+	attrs = append(attrs, l.attrs...)
+	attrs = append(attrs, args...)
+	base.Load().Log(context.Background(), level(messageNumber), text, attrs...)
+}
+
+// ----------------------------------------------------------------------------
+
+// With returns a logger that adds the given key-value pairs to every message.
+func (l *Logger) With(args ...any) *Logger {
+	attrs := make([]any, 0, len(l.attrs)+len(args))
+	attrs = append(attrs, l.attrs...)
+	attrs = append(attrs, args...)
+	return &Logger{
+		attrs:     attrs,
+		component: l.component,
+		messages:  l.messages,
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+// wrap a ReplaceAttr function so that credentials are never logged; the
+// values of sensitive keys are masked and URLs in errors redacted
+func redactAttr(replaceAttr func([]string, slog.Attr) slog.Attr) func([]string, slog.Attr) slog.Attr {
+	return func(groups []string, a slog.Attr) slog.Attr {
+		a = replaceAttr(groups, a)
+		if redact.IsSensitive(a.Key) {
+			return slog.String(a.Key, redact.Mask)
+		}
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, redact.String(err.Error()))
+		}
+		return a
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+// the level of a message follows from its number
+func level(messageNumber int) slog.Level {
+	for _, lowBound := range levelBounds {
+		if messageNumber >= lowBound {
+			return senzinglogging.TextToLevelMap[senzinglogging.IdLevelRangesAsString[lowBound]]
+		}
+	}
+	return senzinglogging.LevelTraceSlog
+}
+
+// ----------------------------------------------------------------------------
+func init() {
+	for lowBound := range senzinglogging.IdLevelRangesAsString {
+		levelBounds = append(levelBounds, lowBound)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(levelBounds)))
+	leveler.Set(senzinglogging.LevelErrorSlog)
+	SetOutput(os.Stderr)
+}
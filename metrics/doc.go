@@ -0,0 +1,13 @@
+/*
+The metrics package counts the records read, loaded, failed, retried and
+skipped by each input, labelled with its scheme and its URL without
+credentials or secret query parameters, and times engine calls and queue
+fetches, for Prometheus to scrape from /metrics.  Go runtime and process
+metrics are included.  The same counts, by DATA_SOURCE and by input and
+with the error codes records failed with, are kept in process for the
+summary at the end of a run; see Snapshot.  Records sent to the engine are
+also counted by operation; add, delete or reevaluate.  Redo records
+processed by the engine are counted with the redo scheme, and kept apart
+from the records read in the totals.
+*/
+package metrics
@@ -0,0 +1,229 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/senzing/go-common/record"
+)
+
+const namespace = "load"
+
+// the scheme redo records are labelled with; they are counted apart from the
+// records read from the input in the totals
+const RedoScheme = "redo"
+
+// labels, see the package documentation
+const (
+	labelDataSource = "data_source"
+	labelInput      = "input"
+	labelOperation  = "operation"
+	labelOutcome    = "outcome"
+	labelScheme     = "scheme"
+)
+
+// outcomes of an operation, see RecordOperation
+const (
+	outcomeFailed    = "failed"
+	outcomeSucceeded = "succeeded"
+)
+
+var (
+	recordsRead = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "records_read_total",
+		Help:      "Records read from the input.",
+	}, []string{labelScheme, labelInput, labelDataSource})
+
+	recordsLoaded = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "records_loaded_total",
+		Help:      "Records added to, deleted from or reevaluated by Senzing.",
+	}, []string{labelScheme, labelInput, labelDataSource})
+
+	recordsFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "records_failed_total",
+		Help:      "Records that could not be loaded.",
+	}, []string{labelScheme, labelInput, labelDataSource})
+
+	recordsRetried = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "records_retried_total",
+		Help:      "Records returned to the queue to be tried again.",
+	}, []string{labelScheme, labelInput, labelDataSource})
+
+	recordsSkipped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "records_skipped_total",
+		Help:      "Valid records that were not sent to the engine, eg. in a dry run.",
+	}, []string{labelScheme, labelInput, labelDataSource})
+
+	operations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "operations_total",
+		Help:      "Records sent to the engine by operation; add, delete or reevaluate, and whether it succeeded.",
+	}, []string{labelScheme, labelOperation, labelOutcome})
+
+	engineLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "engine_call_duration_seconds",
+		Help:      "Time taken by the Senzing engine to add, delete, reevaluate or redo a record.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 15),
+	}, []string{labelScheme, labelDataSource})
+
+	fetchLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "fetch_duration_seconds",
+		Help:      "Time waiting for the next record from the input once a worker is free.",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 12),
+	}, []string{labelScheme})
+
+	workers = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "workers",
+		Help:      "Workers available to add records.",
+	}, []string{labelScheme})
+
+	workersBusy = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "workers_busy",
+		Help:      "Workers adding a record; divide by load_workers for utilization.",
+	}, []string{labelScheme})
+)
+
+// ----------------------------------------------------------------------------
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ----------------------------------------------------------------------------
+
+// DataSource returns the DATA_SOURCE of a record, for labelling; "" when
+// the record is not JSON or has none.
+func DataSource(line string) string {
+	record, _ := record.NewRecord(line)
+	if record == nil {
+		return ""
+	}
+	return record.DataSource
+}
+
+// ----------------------------------------------------------------------------
+
+// RecordRead counts a record read from the input; the input's URL, without
+// credentials.
+func RecordRead(scheme, input, dataSource string) {
+	recordsRead.WithLabelValues(scheme, input, dataSource).Inc()
+	totals.count(input, dataSource, func(c *Counts) { c.Read++ })
+}
+
+// RecordLoaded counts a record added to Senzing.
+func RecordLoaded(scheme, input, dataSource string) {
+	recordsLoaded.WithLabelValues(scheme, input, dataSource).Inc()
+	totals.count(input, dataSource, func(c *Counts) { c.Loaded++ })
+}
+
+// RecordFailed counts a record that could not be loaded, and the error
+// that stopped it.
+func RecordFailed(scheme, input, dataSource string, err error) {
+	recordsFailed.WithLabelValues(scheme, input, dataSource).Inc()
+	totals.count(input, dataSource, func(c *Counts) { c.Failed++ })
+	totals.failedWith(err)
+}
+
+// RecordRejected counts a record that was read but failed before it got to
+// the engine, labelled with its DATA_SOURCE when it has one.
+func RecordRejected(scheme, input, line string, err error) {
+	dataSource := DataSource(line)
+	RecordRead(scheme, input, dataSource)
+	RecordFailed(scheme, input, dataSource, err)
+}
+
+// RecordRetried counts a record returned to the queue to be tried again.
+func RecordRetried(scheme, input, dataSource string) {
+	recordsRetried.WithLabelValues(scheme, input, dataSource).Inc()
+	totals.count(input, dataSource, func(c *Counts) { c.Retried++ })
+}
+
+// RecordSkipped counts a valid record that was not sent to the engine.
+func RecordSkipped(scheme, input, dataSource string) {
+	recordsSkipped.WithLabelValues(scheme, input, dataSource).Inc()
+	totals.count(input, dataSource, func(c *Counts) { c.Skipped++ })
+}
+
+// ----------------------------------------------------------------------------
+
+// RecordOperation counts a record the engine added, deleted or reevaluated,
+// or failed to with the given error.  records that are retried are not
+// counted until the engine is done with them.
+func RecordOperation(scheme, operation string, err error) {
+	outcome := outcomeSucceeded
+	if err != nil {
+		outcome = outcomeFailed
+	}
+	operations.WithLabelValues(scheme, operation, outcome).Inc()
+	totals.countOperation(operation, err == nil)
+}
+
+// ----------------------------------------------------------------------------
+
+// RedoProcessed counts a redo record processed by the engine, labelled with
+// the redo scheme and its DATA_SOURCE when it has one.
+func RedoProcessed(dataSource string) {
+	recordsRead.WithLabelValues(RedoScheme, "", dataSource).Inc()
+	recordsLoaded.WithLabelValues(RedoScheme, "", dataSource).Inc()
+	totals.countRedo(func(c *Counts) {
+		c.Read++
+		c.Loaded++
+	})
+}
+
+// RedoFailed counts a redo record the engine could not process, and the
+// error that stopped it.
+func RedoFailed(dataSource string, err error) {
+	recordsRead.WithLabelValues(RedoScheme, "", dataSource).Inc()
+	recordsFailed.WithLabelValues(RedoScheme, "", dataSource).Inc()
+	totals.countRedo(func(c *Counts) {
+		c.Read++
+		c.Failed++
+	})
+	totals.failedWith(err)
+}
+
+// ----------------------------------------------------------------------------
+
+// EngineCall times a call to the engine; call the returned function once the
+// call returns.
+func EngineCall(scheme, dataSource string) func() {
+	start := time.Now()
+	return func() {
+		engineLatency.WithLabelValues(scheme, dataSource).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Fetch records the time spent waiting for a record from the input.
+func Fetch(scheme string, waited time.Duration) {
+	fetchLatency.WithLabelValues(scheme).Observe(waited.Seconds())
+}
+
+// ----------------------------------------------------------------------------
+
+// Workers sets the number of workers available to the input.
+func Workers(scheme string, count int) {
+	workers.WithLabelValues(scheme).Set(float64(count))
+}
+
+// WorkerBusy marks a worker as busy; call the returned function once it is
+// done with the record.
+func WorkerBusy(scheme string) func() {
+	workersBusy.WithLabelValues(scheme).Inc()
+	return func() {
+		workersBusy.WithLabelValues(scheme).Dec()
+	}
+}
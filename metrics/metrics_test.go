@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/roncewind/load/validate"
+)
+
+// ----------------------------------------------------------------------------
+// Test interface functions
+// ----------------------------------------------------------------------------
+
+func TestHandler(test *testing.T) {
+	RecordRead("file", "file:///data/test.jsonl", "TEST")
+	RecordLoaded("file", "file:///data/test.jsonl", "TEST")
+	EngineCall("file", "TEST")()
+	Workers("file", 4)
+	WorkerBusy("file")()
+
+	server := httptest.NewServer(Handler())
+	defer server.Close()
+	response, err := server.Client().Get(server.URL)
+	if err != nil {
+		test.Fatal(err)
+	}
+	defer response.Body.Close()
+	body, _ := io.ReadAll(response.Body)
+	for _, expected := range []string{
+		`load_records_read_total{data_source="TEST",input="file:///data/test.jsonl",scheme="file"} 1`,
+		`load_records_loaded_total{data_source="TEST",input="file:///data/test.jsonl",scheme="file"} 1`,
+		`load_engine_call_duration_seconds_count{data_source="TEST",scheme="file"} 1`,
+		`load_workers{scheme="file"} 4`,
+		`load_workers_busy{scheme="file"} 0`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(string(body), expected) {
+			test.Errorf("expected %s in the metrics", expected)
+		}
+	}
+}
+
+func TestDataSource(test *testing.T) {
+	if actual := DataSource(`{"DATA_SOURCE": "TEST"}`); actual != "TEST" {
+		test.Errorf("expected TEST, got %q", actual)
+	}
+	if actual := DataSource(`not json`); actual != "" {
+		test.Errorf("expected no data source, got %q", actual)
+	}
+}
+
+func TestSnapshot(test *testing.T) {
+	Reset()
+	RecordRead("file", "file:///data/test.jsonl", "TEST")
+	RecordLoaded("file", "file:///data/test.jsonl", "TEST")
+	RecordRejected("file", "file:///data/test.jsonl", `{"RECORD_ID": "2"}`, validate.ValidationError{Rule: validate.RuleDataSource})
+	RecordRead("file", "file:///data/test.jsonl", "TEST")
+	RecordFailed("file", "file:///data/test.jsonl", "TEST", errors.New("0037E|Unknown resolved entity value"))
+	RecordRead("file", "file:///data/other.jsonl", "OTHER")
+	RecordSkipped("file", "file:///data/other.jsonl", "OTHER")
+	RedoProcessed("TEST")
+	RecordOperation("file", "delete", nil)
+	RecordOperation("file", "delete", errors.New("0033E|Unknown record"))
+
+	totals := Snapshot()
+	expected := Counts{Read: 4, Loaded: 1, Failed: 2, Skipped: 1}
+	if totals.Counts != expected {
+		test.Errorf("expected %+v, got %+v", expected, totals.Counts)
+	}
+	if totals.Redo != (Counts{Read: 1, Loaded: 1}) {
+		test.Errorf("expected one redo record processed, got %+v", totals.Redo)
+	}
+	if totals.Inputs["file:///data/test.jsonl"].Read != 3 || totals.Inputs["file:///data/other.jsonl"].Skipped != 1 {
+		test.Errorf("unexpected counts by input: %+v", totals.Inputs)
+	}
+	if totals.Operations["delete"] != (OperationCounts{Succeeded: 1, Failed: 1}) {
+		test.Errorf("expected one delete succeeded and one failed, got %+v", totals.Operations)
+	}
+	if totals.DataSources["TEST"].Failed != 1 || totals.DataSources[""].Failed != 1 || totals.DataSources["OTHER"].Skipped != 1 {
+		test.Errorf("unexpected counts by data source: %+v", totals.DataSources)
+	}
+	if len(totals.Errors) != 2 || totals.Errors[0].Code != "0037" || totals.Errors[1].Code != validate.RuleDataSource {
+		test.Errorf("unexpected error codes: %+v", totals.Errors)
+	}
+
+	Reset()
+	if totals := Snapshot(); totals.Read != 0 || len(totals.DataSources) != 0 {
+		test.Errorf("expected no records after a reset, got %+v", totals)
+	}
+}
@@ -0,0 +1,217 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/roncewind/load/output"
+	"github.com/roncewind/load/validate"
+)
+
+// the error code of failures that are neither a validation rule nor a
+// Senzing error, eg. a CSV row that cannot be parsed
+const otherErrorCode = "other"
+
+var totals = &tally{}
+
+// ----------------------------------------------------------------------------
+// Types
+// ----------------------------------------------------------------------------
+
+// Counts of records by what happened to them.
+type Counts struct {
+	Read    int64 `json:"read"`
+	Loaded  int64 `json:"loaded"`
+	Failed  int64 `json:"failed"`
+	Retried int64 `json:"retried"`
+	Skipped int64 `json:"skipped"`
+}
+
+// ErrorCount is the number of records that failed with an error code; a
+// Senzing error code, eg. "0037", or the validation rule that was broken.
+type ErrorCount struct {
+	Code  string `json:"code"`
+	Count int64  `json:"count"`
+}
+
+// OperationCounts are the number of records an operation succeeded and failed
+// for.
+type OperationCounts struct {
+	Succeeded int64 `json:"succeeded"`
+	Failed    int64 `json:"failed"`
+}
+
+// Totals are the record counts since the last Reset, in all, for each
+// DATA_SOURCE and for each input, the error codes records failed with, most
+// frequent first, the counts for each operation, the counts of redo records
+// processed and the result of validating the records read, if they were.
+type Totals struct {
+	Counts
+	DataSources map[string]Counts
+	Errors      []ErrorCount
+	Inputs      map[string]Counts
+	Operations  map[string]OperationCounts
+	Redo        Counts
+	Validation  *validate.Result
+}
+
+// the in-process counterpart of the Prometheus counters, for the summary at
+// the end of a run
+type tally struct {
+	lock        sync.Mutex
+	all         Counts
+	dataSources map[string]*Counts
+	errors      map[string]int64
+	inputs      map[string]*Counts
+	operations  map[string]*OperationCounts
+	redo        Counts
+	validation  *validate.Result
+}
+
+// ----------------------------------------------------------------------------
+
+// Reset sets the totals to zero, at the start of a run.  the Prometheus
+// counters are not reset.
+func Reset() {
+	totals.lock.Lock()
+	defer totals.lock.Unlock()
+	totals.all = Counts{}
+	totals.dataSources = nil
+	totals.errors = nil
+	totals.inputs = nil
+	totals.operations = nil
+	totals.redo = Counts{}
+	totals.validation = nil
+}
+
+// ----------------------------------------------------------------------------
+
+// Snapshot returns the totals since the last Reset.
+func Snapshot() Totals {
+	totals.lock.Lock()
+	defer totals.lock.Unlock()
+	snapshot := Totals{
+		Counts:      totals.all,
+		DataSources: make(map[string]Counts, len(totals.dataSources)),
+		Errors:      make([]ErrorCount, 0, len(totals.errors)),
+		Inputs:      make(map[string]Counts, len(totals.inputs)),
+		Operations:  make(map[string]OperationCounts, len(totals.operations)),
+		Redo:        totals.redo,
+		Validation:  totals.validation,
+	}
+	for input, counts := range totals.inputs {
+		snapshot.Inputs[input] = *counts
+	}
+	for operation, counts := range totals.operations {
+		snapshot.Operations[operation] = *counts
+	}
+	for dataSource, counts := range totals.dataSources {
+		snapshot.DataSources[dataSource] = *counts
+	}
+	for code, count := range totals.errors {
+		snapshot.Errors = append(snapshot.Errors, ErrorCount{Code: code, Count: count})
+	}
+	sort.Slice(snapshot.Errors, func(i, j int) bool {
+		if snapshot.Errors[i].Count != snapshot.Errors[j].Count {
+			return snapshot.Errors[i].Count > snapshot.Errors[j].Count
+		}
+		return snapshot.Errors[i].Code < snapshot.Errors[j].Code
+	})
+	return snapshot
+}
+
+// ----------------------------------------------------------------------------
+
+// Validated keeps the result of validating the records read, for the
+// summary.
+func Validated(result validate.Result) {
+	totals.lock.Lock()
+	defer totals.lock.Unlock()
+	totals.validation = &result
+}
+
+// ----------------------------------------------------------------------------
+
+// add to the counts, in all, for the DATA_SOURCE and for the input
+func (t *tally) count(input, dataSource string, add func(*Counts)) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.dataSources == nil {
+		t.dataSources = map[string]*Counts{}
+		t.inputs = map[string]*Counts{}
+	}
+	add(&t.all)
+	add(countsFor(t.dataSources, dataSource))
+	add(countsFor(t.inputs, input))
+}
+
+// ----------------------------------------------------------------------------
+
+// the counts for the key, added when there are none yet
+func countsFor(counts map[string]*Counts, key string) *Counts {
+	keyCounts, ok := counts[key]
+	if !ok {
+		keyCounts = &Counts{}
+		counts[key] = keyCounts
+	}
+	return keyCounts
+}
+
+// ----------------------------------------------------------------------------
+
+// count a record an operation succeeded or failed for
+func (t *tally) countOperation(operation string, succeeded bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.operations == nil {
+		t.operations = map[string]*OperationCounts{}
+	}
+	counts, ok := t.operations[operation]
+	if !ok {
+		counts = &OperationCounts{}
+		t.operations[operation] = counts
+	}
+	if succeeded {
+		counts.Succeeded++
+	} else {
+		counts.Failed++
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+// add to the counts of redo records
+func (t *tally) countRedo(add func(*Counts)) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	add(&t.redo)
+}
+
+// ----------------------------------------------------------------------------
+
+// count the error code a record failed with
+func (t *tally) failedWith(err error) {
+	code := errorCode(err)
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.errors == nil {
+		t.errors = map[string]int64{}
+	}
+	t.errors[code]++
+}
+
+// ----------------------------------------------------------------------------
+
+// the code for the error a record failed with
+func errorCode(err error) string {
+	var validationErr validate.ValidationError
+	if errors.As(err, &validationErr) {
+		return validationErr.Rule
+	}
+	if code := output.ErrorCode(err); code != 0 {
+		return fmt.Sprintf("%04d", code)
+	}
+	return otherErrorCode
+}
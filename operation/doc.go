@@ -0,0 +1,8 @@
+/*
+The operation package routes each record to the engine call it is meant for;
+adding it, deleting it or reevaluating it.  the operation is named by a
+message header or attribute, for the amqp and sqs inputs, or by a reserved
+LOAD_OPERATION field in the record itself, for any input.  records that name
+no operation are added.
+*/
+package operation
@@ -0,0 +1,101 @@
+package operation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/roncewind/load/engine"
+	"github.com/roncewind/load/validate"
+	"github.com/senzing/go-common/record"
+)
+
+// operations a record can be routed to, see Parse
+const (
+	Add        = "add"
+	Delete     = "delete"
+	Reevaluate = "reevaluate"
+)
+
+// Header is the name of the message header, for amqp, kafka and nats, or
+// message attribute, for sqs, that names the operation for the record in a
+// message.  it takes precedence over the record's Field.
+const Header = "operation"
+
+// Field is the reserved record field that names the operation for the
+// record.  it is removed before the record is added.
+const Field = "LOAD_OPERATION"
+
+// adding a record replaces any record with the same DATA_SOURCE and
+// RECORD_ID, so replace is another name for add
+const replace = "replace"
+
+// ----------------------------------------------------------------------------
+
+// Parse returns the operation with the given name, in any case; add when the
+// name is empty.  a validate.ValidationError is returned for any other name.
+func Parse(name string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", Add, replace:
+		return Add, nil
+	case Delete:
+		return Delete, nil
+	case Reevaluate:
+		return Reevaluate, nil
+	}
+	return "", validate.ValidationError{
+		Rule:    validate.RuleOperation,
+		Message: fmt.Sprintf("unsupported operation %q, expected %s, %s, %s or %s", name, Add, replace, Delete, Reevaluate),
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+// Of returns the operation for a record; the one named by the header, when it
+// is given, otherwise the one named by the record's Field.  the record is
+// returned without the Field.  records that are not JSON objects are returned
+// as they are, for the validator to reject.
+func Of(header, line string) (string, string, error) {
+	if !strings.Contains(line, Field) {
+		operation, err := Parse(header)
+		return operation, line, err
+	}
+	attributes := map[string]json.RawMessage{}
+	if err := json.Unmarshal([]byte(line), &attributes); err != nil {
+		operation, err := Parse(header)
+		return operation, line, err
+	}
+	value, ok := attributes[Field]
+	if !ok {
+		operation, err := Parse(header)
+		return operation, line, err
+	}
+	var name string
+	fieldErr := json.Unmarshal(value, &name)
+	if len(strings.TrimSpace(header)) > 0 {
+		name = header
+	} else if fieldErr != nil {
+		return "", line, validate.ValidationError{Rule: validate.RuleOperation, Message: Field + " must be a string"}
+	}
+	delete(attributes, Field)
+	stripped, err := json.Marshal(attributes)
+	if err != nil {
+		return "", line, err
+	}
+	operation, err := Parse(name)
+	return operation, string(stripped), err
+}
+
+// ----------------------------------------------------------------------------
+
+// Apply makes the engine call for the operation on the record.
+func Apply(ctx context.Context, g2engine engine.Engine, operation string, record *record.Record) error {
+	switch operation {
+	case Delete:
+		return g2engine.DeleteRecord(ctx, record.DataSource, record.Id)
+	case Reevaluate:
+		return g2engine.ReevaluateRecord(ctx, record.DataSource, record.Id)
+	}
+	return g2engine.AddRecord(ctx, record.DataSource, record.Id, record.Json)
+}
@@ -0,0 +1,112 @@
+package operation
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/roncewind/load/engine"
+	"github.com/roncewind/load/validate"
+	"github.com/senzing/go-common/record"
+)
+
+// ----------------------------------------------------------------------------
+// Test helpers
+// ----------------------------------------------------------------------------
+
+// a stand-in engine that remembers the last call made
+type fakeEngine struct {
+	engine.Engine
+	called string
+}
+
+func (engine *fakeEngine) AddRecord(ctx context.Context, dataSourceCode, recordID, jsonData string) error {
+	engine.called = Add
+	return nil
+}
+
+func (engine *fakeEngine) DeleteRecord(ctx context.Context, dataSourceCode, recordID string) error {
+	engine.called = Delete
+	return nil
+}
+
+func (engine *fakeEngine) ReevaluateRecord(ctx context.Context, dataSourceCode, recordID string) error {
+	engine.called = Reevaluate
+	return nil
+}
+
+// ----------------------------------------------------------------------------
+// Test interface functions
+// ----------------------------------------------------------------------------
+
+func TestParse(test *testing.T) {
+	testCases := map[string]string{
+		"":            Add,
+		"add":         Add,
+		"Replace":     Add,
+		"DELETE":      Delete,
+		" reevaluate": Reevaluate,
+	}
+	for name, expected := range testCases {
+		actual, err := Parse(name)
+		if err != nil {
+			test.Errorf("Parse(%q): %v", name, err)
+		}
+		if actual != expected {
+			test.Errorf("Parse(%q) = %s, expected %s", name, actual, expected)
+		}
+	}
+	_, err := Parse("upsert")
+	var validationErr validate.ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Rule != validate.RuleOperation {
+		test.Errorf("expected an operation validation error, got %v", err)
+	}
+}
+
+func TestOf(test *testing.T) {
+	testCases := []struct {
+		header   string
+		line     string
+		expected string
+		json     string
+	}{
+		{"", `{"DATA_SOURCE": "TEST", "RECORD_ID": "1"}`, Add, `{"DATA_SOURCE": "TEST", "RECORD_ID": "1"}`},
+		{"delete", `{"DATA_SOURCE": "TEST", "RECORD_ID": "1"}`, Delete, `{"DATA_SOURCE": "TEST", "RECORD_ID": "1"}`},
+		{"", `{"DATA_SOURCE": "TEST", "LOAD_OPERATION": "reevaluate", "RECORD_ID": "1"}`, Reevaluate, `{"DATA_SOURCE":"TEST","RECORD_ID":"1"}`},
+		{"add", `{"DATA_SOURCE": "TEST", "LOAD_OPERATION": "delete", "RECORD_ID": "1"}`, Add, `{"DATA_SOURCE":"TEST","RECORD_ID":"1"}`},
+		{"", `not json LOAD_OPERATION`, Add, `not json LOAD_OPERATION`},
+	}
+	for _, testCase := range testCases {
+		operation, json, err := Of(testCase.header, testCase.line)
+		if err != nil {
+			test.Errorf("Of(%q, %s): %v", testCase.header, testCase.line, err)
+		}
+		if operation != testCase.expected || json != testCase.json {
+			test.Errorf("Of(%q, %s) = %s %s, expected %s %s", testCase.header, testCase.line, operation, json, testCase.expected, testCase.json)
+		}
+	}
+	for _, line := range []string{
+		`{"DATA_SOURCE": "TEST", "LOAD_OPERATION": "upsert", "RECORD_ID": "1"}`,
+		`{"DATA_SOURCE": "TEST", "LOAD_OPERATION": 1, "RECORD_ID": "1"}`,
+	} {
+		if _, _, err := Of("", line); err == nil {
+			test.Errorf("expected an error for %s", line)
+		}
+	}
+}
+
+func TestApply(test *testing.T) {
+	record, err := record.NewRecord(`{"DATA_SOURCE": "TEST", "RECORD_ID": "1"}`)
+	if err != nil {
+		test.Fatal(err)
+	}
+	g2engine := &fakeEngine{}
+	for _, operation := range []string{Add, Delete, Reevaluate} {
+		if err := Apply(context.TODO(), g2engine, operation, record); err != nil {
+			test.Fatal(err)
+		}
+		if g2engine.called != operation {
+			test.Errorf("expected %s, got %s", operation, g2engine.called)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package output
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Senzing error messages carry their code as, eg. "0037E|Unknown resolved entity value"
+var senzingErrorCode = regexp.MustCompile(`([0-9]{4,})[EW]\|`)
+
+// ----------------------------------------------------------------------------
+// Types
+// ----------------------------------------------------------------------------
+
+// DeadLetter describes a record that could not be loaded, so that it can be
+// fixed and replayed.
+type DeadLetter struct {
+	ErrorCode    int    `json:"errorCode"`
+	ErrorMessage string `json:"errorMessage"`
+	Record       string `json:"record"`
+	Source       string `json:"source"`
+	Timestamp    string `json:"timestamp"`
+}
+
+// ----------------------------------------------------------------------------
+
+// WriteDeadLetter writes the original record, the Senzing error code and
+// message, where the record came from and when it failed to the writer.
+func WriteDeadLetter(ctx context.Context, writer Writer, record, source string, loadErr error) error {
+	deadLetter := DeadLetter{
+		ErrorCode:    ErrorCode(loadErr),
+		ErrorMessage: loadErr.Error(),
+		Record:       record,
+		Source:       source,
+		Timestamp:    time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	message, err := json.Marshal(deadLetter)
+	if err != nil {
+		return err
+	}
+	return writer.Write(ctx, string(message))
+}
+
+// ----------------------------------------------------------------------------
+
+// ErrorCode extracts the Senzing error code from an error returned by the
+// engine; 0 when the error did not come from Senzing.
+func ErrorCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	match := senzingErrorCode.FindStringSubmatch(err.Error())
+	if match == nil {
+		return 0
+	}
+	code, _ := strconv.Atoi(match[1])
+	return code
+}
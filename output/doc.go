@@ -0,0 +1,5 @@
+/*
+The output package writes the with-info results of loading records to the
+--output-url; a RabbitMQ exchange, an SQS queue, a JSONL file or stdout.
+*/
+package output
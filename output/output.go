@@ -0,0 +1,265 @@
+package output
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	// how long to wait for RabbitMQ to accept a connection
+	connectTimeout = 30 * time.Second
+	// the RabbitMQ heartbeat interval
+	heartbeat = 10 * time.Second
+)
+
+// ----------------------------------------------------------------------------
+// Types
+// ----------------------------------------------------------------------------
+
+// Writer publishes messages, one at a time, to an output.
+type Writer interface {
+	Close() error
+	Write(ctx context.Context, message string) error
+}
+
+// ----------------------------------------------------------------------------
+
+// NewWriter creates a Writer for the given output URL:
+//   - amqp://... publishes to a RabbitMQ exchange (exchange and queue-name
+//     query parameters are required)
+//   - sqs://...?queue-name=... or an https:// SQS queue URL publishes to SQS
+//   - file:///path appends JSON lines to a file
+//   - an empty URL writes JSON lines to stdout
+//
+// nothing else is written to stdout, so that it can be read as the JSON lines
+// and the end of run summary alone.
+func NewWriter(ctx context.Context, urlString string) (Writer, error) {
+	if len(urlString) == 0 {
+		return newFileWriter(os.Stdout), nil
+	}
+	u, err := url.Parse(urlString)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "amqp":
+		return newRabbitWriter(u)
+	case "sqs", "https":
+		return newSQSWriter(ctx, u)
+	case "file":
+		file, err := os.OpenFile(u.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		return newFileWriter(file), nil
+	default:
+		return nil, fmt.Errorf("unable to write to %s URLs", u.Scheme)
+	}
+}
+
+// ----------------------------------------------------------------------------
+// queue message implementation
+// ----------------------------------------------------------------------------
+
+var messageCount int64
+
+type message struct {
+	body string
+	id   string
+}
+
+// create a message identified by the record it describes, when possible
+func newMessage(body string) *message {
+	var record struct {
+		DataSource string `json:"DATA_SOURCE"`
+		Id         string `json:"RECORD_ID"`
+	}
+	count := atomic.AddInt64(&messageCount, 1)
+	id := fmt.Sprintf("%d-%d", time.Now().UnixNano(), count)
+	if json.Unmarshal([]byte(body), &record) == nil && len(record.DataSource) > 0 {
+		id = fmt.Sprintf("%s-%s-%d", record.DataSource, record.Id, count)
+	}
+	return &message{body: body, id: id}
+}
+
+// ----------------------------------------------------------------------------
+// RabbitMQ
+// ----------------------------------------------------------------------------
+
+type rabbitWriter struct {
+	channel    *amqp.Channel
+	connection *amqp.Connection
+	exchange   string
+	routingKey string
+}
+
+// connect to RabbitMQ and declare the exchange, queue and binding, as the
+// RabbitMQ input does, on a channel that confirms what is published
+func newRabbitWriter(u *url.URL) (*rabbitWriter, error) {
+	query := u.Query()
+	exchange, queue := query.Get("exchange"), query.Get("queue-name")
+	if exchange == "" || queue == "" {
+		return nil, errors.New("the RabbitMQ URL does not name an exchange and a queue-name")
+	}
+	routingKey := query.Get("routing-key")
+	if routingKey == "" {
+		routingKey = queue
+	}
+	connection, err := amqp.DialConfig(u.String(), amqp.Config{
+		Dial:      amqp.DefaultDial(connectTimeout),
+		Heartbeat: heartbeat,
+		Locale:    "en_US",
+	})
+	if err != nil {
+		return nil, err
+	}
+	channel, err := connection.Channel()
+	if err == nil {
+		err = channel.Confirm(false)
+	}
+	if err == nil {
+		err = channel.ExchangeDeclare(exchange, amqp.ExchangeDirect, false, false, false, false, nil)
+	}
+	if err == nil {
+		_, err = channel.QueueDeclare(queue, false, false, false, false, nil)
+	}
+	if err == nil {
+		err = channel.QueueBind(queue, routingKey, exchange, false, nil)
+	}
+	if err != nil {
+		connection.Close()
+		return nil, fmt.Errorf("unable to declare the RabbitMQ queue %s: %w", queue, err)
+	}
+	return &rabbitWriter{
+		channel:    channel,
+		connection: connection,
+		exchange:   exchange,
+		routingKey: routingKey,
+	}, nil
+}
+
+func (w *rabbitWriter) Close() error {
+	return w.connection.Close()
+}
+
+// publish the message and wait for RabbitMQ to confirm it
+func (w *rabbitWriter) Write(ctx context.Context, body string) error {
+	m := newMessage(body)
+	confirmation, err := w.channel.PublishWithDeferredConfirmWithContext(ctx, w.exchange, w.routingKey, false, false, amqp.Publishing{
+		Body:         []byte(m.body),
+		ContentType:  "text/plain",
+		DeliveryMode: amqp.Persistent,
+		MessageId:    m.id,
+	})
+	if err != nil {
+		return err
+	}
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !acked {
+		return fmt.Errorf("RabbitMQ did not accept message %s", m.id)
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------------------
+// SQS
+// ----------------------------------------------------------------------------
+
+type sqsWriter struct {
+	client   *sqs.Client
+	queueURL string
+}
+
+// a writer to the SQS queue URL, or to the queue named by its queue-name
+func newSQSWriter(ctx context.Context, u *url.URL) (*sqsWriter, error) {
+	awsConfig, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	writer := &sqsWriter{
+		client:   sqs.NewFromConfig(awsConfig),
+		queueURL: u.String(),
+	}
+	if queueName := u.Query().Get("queue-name"); queueName != "" {
+		output, err := writer.client.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{QueueName: aws.String(queueName)})
+		if err != nil {
+			return nil, err
+		}
+		writer.queueURL = aws.ToString(output.QueueUrl)
+	}
+	return writer, nil
+}
+
+func (w *sqsWriter) Close() error {
+	return nil
+}
+
+func (w *sqsWriter) Write(ctx context.Context, body string) error {
+	m := newMessage(body)
+	_, err := w.client.SendMessage(ctx, &sqs.SendMessageInput{
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"MessageID": {
+				DataType:    aws.String("String"),
+				StringValue: aws.String(m.id),
+			},
+		},
+		MessageBody: aws.String(m.body),
+		QueueUrl:    aws.String(w.queueURL),
+	})
+	return err
+}
+
+// ----------------------------------------------------------------------------
+// JSONL file
+// ----------------------------------------------------------------------------
+
+type fileWriter struct {
+	file   *os.File
+	lock   sync.Mutex
+	writer *bufio.Writer
+}
+
+func newFileWriter(file *os.File) *fileWriter {
+	return &fileWriter{
+		file:   file,
+		writer: bufio.NewWriter(file),
+	}
+}
+
+func (w *fileWriter) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	err := w.writer.Flush()
+	if w.file != os.Stdout {
+		if closeErr := w.file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+func (w *fileWriter) Write(ctx context.Context, body string) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if _, err := w.writer.WriteString(body); err != nil {
+		return err
+	}
+	return w.writer.WriteByte('\n')
+}
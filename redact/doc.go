@@ -0,0 +1,7 @@
+/*
+The redact package hides credentials in configuration before it is logged.
+URLs lose their password and the values of secret query parameters, values
+of sensitive keys are hidden altogether and JSON, eg. the engine
+configuration, is redacted key by key.
+*/
+package redact
@@ -0,0 +1,8 @@
+/*
+The redo package processes the redo records Senzing leaves behind as records
+are added, so that entity resolution does not go stale.  redo records can be
+processed once the input has been read, alongside loading with their own
+workers, or on their own with `load redo`.  records processed, and those
+that fail, are counted by the metrics package with the redo scheme.
+*/
+package redo
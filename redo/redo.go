@@ -0,0 +1,135 @@
+package redo
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/roncewind/load/engine"
+	"github.com/roncewind/load/health"
+	"github.com/roncewind/load/limit"
+	"github.com/roncewind/load/logging"
+	"github.com/roncewind/load/metrics"
+)
+
+var log = logging.New("redo", map[int]string{
+	2701: "Processing redo records",
+	2702: "Finished processing redo records",
+	2703: "Redo records processed",
+	4701: "Unable to process a redo record",
+})
+
+// when redo records are processed by the loader, see --redo-mode
+const (
+	ModeAfter      = "after"
+	ModeConcurrent = "concurrent"
+	ModeOff        = "off"
+)
+
+// how long a worker waits before looking for redo records again, once there
+// are none or the engine failed
+var PollInterval = 5 * time.Second
+
+// ----------------------------------------------------------------------------
+
+// CheckMode returns an error for an unknown redo mode.
+func CheckMode(mode string) error {
+	switch mode {
+	case ModeAfter, ModeConcurrent, ModeOff:
+		return nil
+	}
+	return fmt.Errorf("unknown redo mode %q, expected %s, %s or %s", mode, ModeOff, ModeAfter, ModeConcurrent)
+}
+
+// ----------------------------------------------------------------------------
+
+// Process redo records with the given number of workers until the read
+// context is done; the redo records in flight are finished with the context.
+// when untilEmpty is set, it also stops once there are no redo records left,
+// otherwise it waits for more.  each redo record processed is taken from the
+// limiter, which may be nil; as a redo record is only known once it has been
+// processed, up to a record per worker more than the maximum may be.
+func Process(ctx, readCtx context.Context, g2engine engine.Engine, numberOfWorkers int, limiter *limit.Limiter, untilEmpty bool) {
+
+	//default to the max number of OS threads
+	if numberOfWorkers <= 0 {
+		numberOfWorkers = runtime.GOMAXPROCS(0)
+	}
+	log.Log(2701, "workers", numberOfWorkers, "untilEmpty", untilEmpty)
+	metrics.Workers(metrics.RedoScheme, numberOfWorkers)
+
+	var processed int64
+	var wg sync.WaitGroup
+	for i := 0; i < numberOfWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			work(ctx, readCtx, g2engine, limiter, untilEmpty, &processed)
+		}()
+	}
+	wg.Wait()
+	log.Log(2702, "processed", atomic.LoadInt64(&processed))
+}
+
+// ----------------------------------------------------------------------------
+
+// a single worker; process redo records one at a time until there are no
+// more to process
+func work(ctx, readCtx context.Context, g2engine engine.Engine, limiter *limit.Limiter, untilEmpty bool, processed *int64) {
+	for readCtx.Err() == nil {
+		redoRecord, err := processRedoRecord(ctx, g2engine)
+		if err != nil {
+			log.Log(4701, "error", err)
+			if ctx.Err() != nil {
+				return
+			}
+			metrics.RedoFailed("", err)
+			wait(readCtx)
+			continue
+		}
+		if len(redoRecord) == 0 {
+			if untilEmpty {
+				return
+			}
+			wait(readCtx)
+			continue
+		}
+
+		metrics.RedoProcessed(metrics.DataSource(redoRecord))
+		if count := atomic.AddInt64(processed, 1); count%10000 == 0 {
+			log.Log(2703, "processed", count)
+		}
+		taken := limiter.Take()
+		limiter.Done()
+		if !taken {
+			return
+		}
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+// process the next redo record, marking the worker busy while the engine
+// works on it
+func processRedoRecord(ctx context.Context, g2engine engine.Engine) (string, error) {
+	defer metrics.WorkerBusy(metrics.RedoScheme)()
+	defer health.WorkerBusy()()
+	engineCallDone := metrics.EngineCall(metrics.RedoScheme, "")
+	defer engineCallDone()
+	return g2engine.ProcessRedoRecord(ctx)
+}
+
+// ----------------------------------------------------------------------------
+
+// wait before looking for redo records again, unless reading stops first
+func wait(readCtx context.Context) {
+	timer := time.NewTimer(PollInterval)
+	defer timer.Stop()
+	select {
+	case <-readCtx.Done():
+	case <-timer.C:
+	}
+}
@@ -0,0 +1,96 @@
+package redo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/roncewind/load/engine"
+	"github.com/roncewind/load/limit"
+	"github.com/roncewind/load/metrics"
+)
+
+// ----------------------------------------------------------------------------
+// Test helpers
+// ----------------------------------------------------------------------------
+
+// a stand-in engine with a number of redo records to process, the first of
+// which fails
+type fakeEngine struct {
+	engine.Engine
+	lock      sync.Mutex
+	failFirst bool
+	redo      int
+}
+
+func (engine *fakeEngine) ProcessRedoRecord(ctx context.Context) (string, error) {
+	engine.lock.Lock()
+	defer engine.lock.Unlock()
+	if engine.failFirst {
+		engine.failFirst = false
+		return "", errors.New("0037E|Unknown resolved entity value")
+	}
+	if engine.redo == 0 {
+		return "", nil
+	}
+	engine.redo--
+	return `{"DATA_SOURCE": "TEST", "RECORD_ID": "1"}`, nil
+}
+
+// ----------------------------------------------------------------------------
+// Test interface functions
+// ----------------------------------------------------------------------------
+
+func TestProcess_untilEmpty(test *testing.T) {
+	PollInterval = 10 * time.Millisecond
+	metrics.Reset()
+	ctx := context.TODO()
+	Process(ctx, ctx, &fakeEngine{redo: 5, failFirst: true}, 2, nil, true)
+	if redo := metrics.Snapshot().Redo; redo.Loaded != 5 || redo.Failed != 1 {
+		test.Errorf("expected 5 redo records processed and 1 failed, got %+v", redo)
+	}
+}
+
+func TestProcess_limit(test *testing.T) {
+	metrics.Reset()
+	ctx := context.TODO()
+	limiter, readCtx := limit.New(ctx, 3, 0, 0)
+	defer limiter.Close()
+	Process(ctx, readCtx, &fakeEngine{redo: 10}, 1, limiter, false)
+	if redo := metrics.Snapshot().Redo; redo.Loaded != 3 {
+		test.Errorf("expected 3 redo records processed, got %+v", redo)
+	}
+}
+
+func TestProcess_waits(test *testing.T) {
+	PollInterval = 10 * time.Millisecond
+	ctx := context.TODO()
+	readCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Process(ctx, readCtx, &fakeEngine{}, 1, nil, false)
+	}()
+	select {
+	case <-done:
+		if readCtx.Err() == nil {
+			test.Error("expected processing to wait for redo records until reading stops")
+		}
+	case <-time.After(time.Second):
+		test.Fatal("expected processing to stop once reading stops")
+	}
+}
+
+func TestCheckMode(test *testing.T) {
+	for _, mode := range []string{ModeAfter, ModeConcurrent, ModeOff} {
+		if err := CheckMode(mode); err != nil {
+			test.Error(err)
+		}
+	}
+	if err := CheckMode("sometimes"); err == nil {
+		test.Error("expected an error for an unknown mode")
+	}
+}
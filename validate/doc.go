@@ -0,0 +1,5 @@
+/*
+The validate package checks records against the Generic Entity Specification
+before they are handed to the engine, counting each rule that is broken.
+*/
+package validate
@@ -0,0 +1,284 @@
+package validate
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// validation modes, see --validation-mode
+const (
+	// only records the engine cannot load are rejected; other violations are
+	// counted and the record is loaded as is
+	ModeLenient = "lenient"
+	// records with any violation are rejected
+	ModeStrict = "strict"
+)
+
+// rules records are checked against, as named in the summary
+const (
+	RuleAttributeName = "attribute-name"
+	RuleDataSource    = "data-source"
+	RuleInvalidJSON   = "invalid-json"
+	RuleOperation     = "operation"
+	RuleRecordID      = "record-id"
+	RuleValueType     = "value-type"
+)
+
+// rules that reject a record in lenient mode too
+var requiredRules = map[string]bool{
+	RuleDataSource:  true,
+	RuleInvalidJSON: true,
+	RuleRecordID:    true,
+}
+
+// Generic Entity Specification attribute names, eg. NAME_FULL, ADDR_LINE1
+var attributeName = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// ----------------------------------------------------------------------------
+// Types
+// ----------------------------------------------------------------------------
+
+// ValidationError is returned for a record that should not be loaded.
+type ValidationError struct {
+	Rule    string
+	Message string
+}
+
+func (err ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", err.Rule, err.Message)
+}
+
+// DataSourceCount is the number of records for a single DATA_SOURCE that
+// passed and failed validation.
+type DataSourceCount struct {
+	Bad  int64 `json:"bad"`
+	Good int64 `json:"good"`
+}
+
+// Result is the number of records checked and rejected, the number of times
+// each rule was broken and the good and bad records for each DATA_SOURCE.
+type Result struct {
+	Mode        string                     `json:"mode"`
+	Checked     int64                      `json:"checked"`
+	Rejected    int64                      `json:"rejected"`
+	Rules       map[string]int64           `json:"rules"`
+	DataSources map[string]DataSourceCount `json:"dataSources"`
+}
+
+// Validator checks records and keeps count of the rules they break.  it is
+// safe for use by concurrent workers.
+type Validator struct {
+	mode string
+
+	lock        sync.Mutex
+	checked     int64
+	rejected    int64
+	counts      map[string]int64
+	dataSources map[string]*DataSourceCount
+}
+
+// ----------------------------------------------------------------------------
+
+// NewValidator returns a validator for the given mode; strict or lenient.
+// lenient when no mode is given.
+func NewValidator(mode string) (*Validator, error) {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	switch mode {
+	case "":
+		mode = ModeLenient
+	case ModeLenient, ModeStrict:
+	default:
+		return nil, fmt.Errorf("unsupported validation mode %q, expected %s or %s", mode, ModeLenient, ModeStrict)
+	}
+	return &Validator{
+		mode:        mode,
+		counts:      map[string]int64{},
+		dataSources: map[string]*DataSourceCount{},
+	}, nil
+}
+
+// ----------------------------------------------------------------------------
+
+// Validate checks a single JSON record.  a ValidationError for the first
+// rejecting violation is returned when the record should not be loaded.
+func (v *Validator) Validate(record string) error {
+	dataSource, violations := check(record)
+
+	var rejection error
+	for _, violation := range violations {
+		if rejection == nil && (v.mode == ModeStrict || requiredRules[violation.Rule]) {
+			rejection = violation
+		}
+	}
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	v.checked++
+	for _, violation := range violations {
+		v.counts[violation.Rule]++
+	}
+	count, ok := v.dataSources[dataSource]
+	if !ok {
+		count = &DataSourceCount{}
+		v.dataSources[dataSource] = count
+	}
+	if rejection != nil {
+		v.rejected++
+		count.Bad++
+	} else {
+		count.Good++
+	}
+	return rejection
+}
+
+// ----------------------------------------------------------------------------
+
+// Counts returns the number of times each rule was broken.
+func (v *Validator) Counts() map[string]int64 {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	counts := make(map[string]int64, len(v.counts))
+	for rule, count := range v.counts {
+		counts[rule] = count
+	}
+	return counts
+}
+
+// ----------------------------------------------------------------------------
+
+// DataSources returns the number of good and bad records checked for each
+// DATA_SOURCE; records without one are counted under "".
+func (v *Validator) DataSources() map[string]DataSourceCount {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	dataSources := make(map[string]DataSourceCount, len(v.dataSources))
+	for dataSource, count := range v.dataSources {
+		dataSources[dataSource] = *count
+	}
+	return dataSources
+}
+
+// ----------------------------------------------------------------------------
+
+// Result returns the records checked and rejected so far, the number of
+// times each rule was broken and the good and bad records for each
+// DATA_SOURCE.
+func (v *Validator) Result() Result {
+	counts := v.Counts()
+	dataSources := v.DataSources()
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	return Result{
+		Mode:        v.mode,
+		Checked:     v.checked,
+		Rejected:    v.rejected,
+		Rules:       counts,
+		DataSources: dataSources,
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+// Summary describes the records checked, rejected and the count for each
+// rule that was broken.
+func (v *Validator) Summary() string {
+	counts := v.Counts()
+	rules := make([]string, 0, len(counts))
+	for rule := range counts {
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+
+	v.lock.Lock()
+	summary := fmt.Sprintf("Validation (%s): %d records checked, %d rejected", v.mode, v.checked, v.rejected)
+	v.lock.Unlock()
+	for _, rule := range rules {
+		summary += fmt.Sprintf(", %s: %d", rule, counts[rule])
+	}
+	return summary
+}
+
+// ----------------------------------------------------------------------------
+
+// find the DATA_SOURCE of the record and every violation in it.  records
+// that are not a JSON object are not checked any further.
+func check(record string) (string, []ValidationError) {
+	var attributes map[string]interface{}
+	if err := json.Unmarshal([]byte(record), &attributes); err != nil || attributes == nil {
+		message := "record is not a JSON object"
+		if err != nil {
+			message = err.Error()
+		}
+		return "", []ValidationError{{RuleInvalidJSON, message}}
+	}
+	dataSource, _ := attributes["DATA_SOURCE"].(string)
+
+	var violations []ValidationError
+	for _, name := range []string{"DATA_SOURCE", "RECORD_ID"} {
+		rule := RuleDataSource
+		if name == "RECORD_ID" {
+			rule = RuleRecordID
+		}
+		value, ok := attributes[name]
+		if !ok {
+			violations = append(violations, ValidationError{rule, name + " is missing"})
+			continue
+		}
+		if str, ok := value.(string); !ok || len(strings.TrimSpace(str)) == 0 {
+			violations = append(violations, ValidationError{rule, name + " must be a non-empty string"})
+		}
+	}
+
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == "DATA_SOURCE" || name == "RECORD_ID" {
+			continue
+		}
+		violations = append(violations, checkAttribute(name, attributes[name])...)
+	}
+	return dataSource, violations
+}
+
+// ----------------------------------------------------------------------------
+
+// check the name and value of a single attribute.  values are strings or
+// numbers, or a list of objects holding strings and numbers for repeated
+// features, eg. "NAMES": [{"NAME_TYPE": "PRIMARY", "NAME_FULL": "..."}]
+func checkAttribute(name string, value interface{}) []ValidationError {
+	var violations []ValidationError
+	if !attributeName.MatchString(name) {
+		violations = append(violations, ValidationError{RuleAttributeName, fmt.Sprintf("%q is not an upper case attribute name", name)})
+	}
+	switch value := value.(type) {
+	case string, float64:
+	case []interface{}:
+		for _, item := range value {
+			feature, ok := item.(map[string]interface{})
+			if !ok {
+				violations = append(violations, ValidationError{RuleValueType, fmt.Sprintf("%s must be a list of objects", name)})
+				continue
+			}
+			for featureName, featureValue := range feature {
+				if !attributeName.MatchString(featureName) {
+					violations = append(violations, ValidationError{RuleAttributeName, fmt.Sprintf("%q in %s is not an upper case attribute name", featureName, name)})
+				}
+				switch featureValue.(type) {
+				case string, float64:
+				default:
+					violations = append(violations, ValidationError{RuleValueType, fmt.Sprintf("%s in %s must be a string or number", featureName, name)})
+				}
+			}
+		}
+	default:
+		violations = append(violations, ValidationError{RuleValueType, fmt.Sprintf("%s must be a string, number or list of objects", name)})
+	}
+	return violations
+}
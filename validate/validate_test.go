@@ -0,0 +1,85 @@
+package validate
+
+import (
+	"testing"
+)
+
+// ----------------------------------------------------------------------------
+// Test interface functions
+// ----------------------------------------------------------------------------
+
+func TestValidate(test *testing.T) {
+	testCases := []struct {
+		name    string
+		record  string
+		lenient string
+		strict  string
+	}{
+		{"valid", `{"DATA_SOURCE": "TEST", "RECORD_ID": "1", "NAME_FULL": "Robert Smith", "AMOUNT": 100}`, "", ""},
+		{"feature list", `{"DATA_SOURCE": "TEST", "RECORD_ID": "1", "NAMES": [{"NAME_TYPE": "PRIMARY", "NAME_FULL": "Robert Smith"}]}`, "", ""},
+		{"not json", `not json`, RuleInvalidJSON, RuleInvalidJSON},
+		{"not an object", `["DATA_SOURCE"]`, RuleInvalidJSON, RuleInvalidJSON},
+		{"null", `null`, RuleInvalidJSON, RuleInvalidJSON},
+		{"missing data source", `{"RECORD_ID": "1"}`, RuleDataSource, RuleDataSource},
+		{"missing record id", `{"DATA_SOURCE": "TEST"}`, RuleRecordID, RuleRecordID},
+		{"numeric record id", `{"DATA_SOURCE": "TEST", "RECORD_ID": 1}`, RuleRecordID, RuleRecordID},
+		{"lower case name", `{"DATA_SOURCE": "TEST", "RECORD_ID": "1", "name_full": "Robert Smith"}`, "", RuleAttributeName},
+		{"nested object", `{"DATA_SOURCE": "TEST", "RECORD_ID": "1", "NAME": {"NAME_FULL": "Robert Smith"}}`, "", RuleValueType},
+		{"list of strings", `{"DATA_SOURCE": "TEST", "RECORD_ID": "1", "NAMES": ["Robert Smith"]}`, "", RuleValueType},
+	}
+	for _, testCase := range testCases {
+		test.Run(testCase.name, func(test *testing.T) {
+			for mode, expected := range map[string]string{ModeLenient: testCase.lenient, ModeStrict: testCase.strict} {
+				validator, err := NewValidator(mode)
+				if err != nil {
+					test.Fatal(err)
+				}
+				actual := ""
+				if err := validator.Validate(testCase.record); err != nil {
+					actual = err.(ValidationError).Rule
+				}
+				if actual != expected {
+					test.Errorf("%s: expected %q, got %q", mode, expected, actual)
+				}
+			}
+		})
+	}
+}
+
+func TestNewValidator(test *testing.T) {
+	// callers that do not set a mode get the lenient validation they had
+	// before there was one
+	validator, err := NewValidator("")
+	if err != nil {
+		test.Fatal(err)
+	}
+	if err := validator.Validate(`{"DATA_SOURCE": "TEST", "RECORD_ID": "1", "name_full": "Robert Smith"}`); err != nil {
+		test.Errorf("expected the record to be loaded, got %v", err)
+	}
+	if _, err := NewValidator("picky"); err == nil {
+		test.Error("expected an error for an unsupported mode")
+	}
+}
+
+func TestSummary(test *testing.T) {
+	validator, err := NewValidator("Lenient")
+	if err != nil {
+		test.Fatal(err)
+	}
+	validator.Validate(`{"DATA_SOURCE": "TEST", "RECORD_ID": "1", "name": "a", "other": "b"}`)
+	validator.Validate(`{"DATA_SOURCE": "TEST"}`)
+	expected := "Validation (lenient): 2 records checked, 1 rejected, attribute-name: 2, record-id: 1"
+	if actual := validator.Summary(); actual != expected {
+		test.Errorf("expected %q, got %q", expected, actual)
+	}
+	result := validator.Result()
+	if result.Mode != ModeLenient || result.Checked != 2 || result.Rejected != 1 || result.Rules[RuleAttributeName] != 2 || result.Rules[RuleRecordID] != 1 {
+		test.Errorf("Result() = %+v", result)
+	}
+	if result.DataSources["TEST"] != (DataSourceCount{Bad: 1, Good: 1}) {
+		test.Errorf("expected 1 good and 1 bad TEST record, got %+v", result.DataSources)
+	}
+	if _, err := NewValidator("picky"); err == nil {
+		test.Error("expected an error for an unsupported mode")
+	}
+}